@@ -0,0 +1,189 @@
+package main
+
+import (
+    "time"
+)
+
+/*
+Enhanced Other Networks, what a station says about the other stations of its
+network
+
+* 14A rdsb: TP(ON)               : ...._...._...x_....
+            variant code         : ...._...._...._xxxx
+      rdsc: information, by variant
+             0..3 : PS(ON), 2 chars
+                4 : AF(ON), 2 AF codes (method A)
+             5..8 : mapped FM frequency, tuning network : xxxx_xxxx_...._....
+                                         other network  : ...._...._xxxx_xxxx
+                9 : mapped AM frequency, as 5..8
+           10..11 : unallocated
+               12 : linkage information
+               13 : PTY(ON)          : xxxx_x..._...._....
+                    TA(ON)           : ...._...._...._...x
+               14 : PIN(ON)
+               15 : reserved for broadcasters
+      rdsd: PI(ON)
+* 14B rdsb: TP(ON)               : ...._...._...x_....
+            TA(ON)               : ...._...._...._x...
+      rdsc: PI(TN)
+      rdsd: PI(ON)
+*/
+
+// OtherNetwork is what the tuned station says about another station
+type OtherNetwork struct {
+    ProgramInformation   uint16  // PI(ON)
+    ProgramService       string  // PS(ON)
+    TrafficProgram       bool    // TP(ON)
+    TrafficAnnouncement  bool    // TA(ON)
+    ProgramType          int     // PTY(ON)
+    ProgramItem          PIN     // PIN(ON)
+    AltFreqs             map[float64]bool     // AF(ON)
+    MappedFreqs          map[float64]float64  // tuned station's frequency to the other station's
+    Linkage              uint16  // LI(ON) - linkage information
+
+    // program service triple buffering
+    ps1    [8]byte
+    ps2    [8]byte
+}
+
+// TrafficEvent is the start or end of a traffic announcement on another
+// network, signaled by a burst of 14B groups
+type TrafficEvent struct {
+    ProgramInformation   uint16  // PI(ON)
+    TrafficProgram       bool
+    TrafficAnnouncement  bool
+    Time                 time.Time
+}
+
+// the 14B groups of one burst arrive within this long of each other
+const eon_burst = 5 * time.Second
+
+// the most traffic events kept
+const max_traffic_events = 32
+
+// other_network returns the network with PI pi, adding it if it's new
+func (r *RDS) other_network(pi uint16) *OtherNetwork {
+    if r.OtherNetworks == nil {
+        r.OtherNetworks = map[uint16]*OtherNetwork{}
+    }
+    on, ok := r.OtherNetworks[pi]
+    if !ok {
+        on = &OtherNetwork{ProgramInformation: pi}
+        r.OtherNetworks[pi] = on
+    }
+    return on
+}
+
+func (r *RDS) update_eon(rdsa, rdsb, rdsc, rdsd uint16) {
+    var i, idx int
+    var upd bool
+
+    on := r.other_network(rdsd)
+    on.TrafficProgram = rdsb & 0x10 == 0x10
+
+    switch variant := rdsb & 0xf; variant {
+        case 0, 1, 2, 3:
+            idx = int(variant) * 2
+            on.ps1[idx] = byte((rdsc>>8) & 0x7f)
+            on.ps1[idx+1] = byte(rdsc & 0x7f)
+            if idx == 0 {
+                // triple buffer, only update if we've seen the same thing twice
+                upd = true
+                for i=0; i<8; i++ {
+                    if on.ps1[i] != on.ps2[i] {
+                        upd = false
+                    }
+                }
+                if upd {
+                    on.ProgramService = string(on.ps2[0:8])
+                }
+                for i=0; i<8; i++ {
+                    on.ps2[i] = on.ps1[i]
+                }
+            }
+        case 4:
+            if on.AltFreqs == nil {
+                on.AltFreqs = map[float64]bool{}
+            }
+            for _, f := range []uint16{rdsc>>8, rdsc&0xff} {
+                if f>=1 && f<=204 {
+                    on.AltFreqs[fm_freq(f)] = true
+                }
+            }
+        case 5, 6, 7, 8, 9:
+            tn, other := rdsc>>8, rdsc&0xff
+            if tn < 1 || tn > 204 {
+                break
+            }
+            if on.MappedFreqs == nil {
+                on.MappedFreqs = map[float64]float64{}
+            }
+            if variant == 9 {
+                if f := am_freq(other); f != 0 {
+                    on.MappedFreqs[fm_freq(tn)] = f
+                }
+            } else if other>=1 && other<=204 {
+                on.MappedFreqs[fm_freq(tn)] = fm_freq(other)
+            }
+        case 12:
+            on.Linkage = rdsc
+        case 13:
+            on.ProgramType = int(rdsc>>11)
+            on.TrafficAnnouncement = rdsc & 0x1 == 0x1
+        case 14:
+            on.ProgramItem = PIN{
+                Day: int((rdsc>>11) & 0x1f),
+                Hour: int((rdsc>>6) & 0x1f),
+                Minute: int(rdsc & 0x3f),
+            }
+        // 10, 11: unallocated, 15: for use by broadcasters
+    }
+}
+
+func (r *RDS) update_eon_ta(rdsa, rdsb, rdsc, rdsd uint16) {
+    now := time.Now()
+    on := r.other_network(rdsd)
+    on.TrafficProgram = rdsb & 0x10 == 0x10
+    on.TrafficAnnouncement = rdsb & 0x08 == 0x08
+
+    // one event per burst
+    for i:=len(r.TrafficEvents)-1; i>=0; i-- {
+        e := r.TrafficEvents[i]
+        if e.ProgramInformation != rdsd {
+            continue
+        }
+        if e.TrafficAnnouncement == on.TrafficAnnouncement && now.Sub(e.Time) < eon_burst {
+            r.TrafficEvents[i].Time = now
+            return
+        }
+        break
+    }
+    r.TrafficEvents = append(r.TrafficEvents, TrafficEvent{
+        ProgramInformation: rdsd,
+        TrafficProgram: on.TrafficProgram,
+        TrafficAnnouncement: on.TrafficAnnouncement,
+        Time: now,
+    })
+    if len(r.TrafficEvents) > max_traffic_events {
+        r.TrafficEvents = r.TrafficEvents[len(r.TrafficEvents)-max_traffic_events:]
+    }
+}
+
+// fm_freq returns the frequency in MHz of an AF code from 1 to 204
+func fm_freq(code uint16) float64 {
+    return 87.5 + (float64(code)*.1)
+}
+
+// am_freq returns the frequency in MHz of a LF/MF AF code, or 0 if it isn't
+// one
+func am_freq(code uint16) float64 {
+    switch {
+        case code>=1 && code<=15:
+            // LF, 153kHz..279kHz in 9kHz steps
+            return float64(153 + (code-1)*9) / 1000
+        case code>=16 && code<=135:
+            // MF, 531kHz..1602kHz in 9kHz steps
+            return float64(531 + (code-16)*9) / 1000
+    }
+    return 0
+}
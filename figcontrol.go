@@ -0,0 +1,417 @@
+package main
+
+import (
+    "bufio"
+    "errors"
+    "fmt"
+    "io"
+    "strconv"
+    "strings"
+    "unicode"
+)
+
+// See: "CONTROL FILES" in figlet(6) and figfont.txt
+
+// FIGcontrol decodes input text and translates characters before a FIGfont
+// looks up FIGcharacters.  Unlike figlet, input is assumed to be UTF-8 until
+// the control file selects another encoding, since Go strings are UTF-8.
+type FIGcontrol struct {
+    mode    int
+    moded   bool  // whether the file chose the input encoding
+    stages  []map[rune]rune
+
+    // ISO 2022 state
+    g       [4]charset
+    gl      int
+    gr      int
+}
+
+// input encodings
+const (
+    input_utf8 = iota
+    input_iso2022
+    input_dbcs
+    input_hz
+    input_shiftjis
+)
+
+// an ISO 2022 character set designated into G0..G3
+type charset struct {
+    base    rune  // added to the 7-bit character code
+    double  bool  // 94x94 set, two bytes per character
+}
+
+var ErrInvalidControl = errors.New("invalid FIGlet control file")
+
+func NewFIGcontrol(r io.Reader) (*FIGcontrol, error) {
+    var err error
+    var line string
+    var lineno int
+
+    c := FIGcontrol{
+        mode: input_utf8,
+        stages: []map[rune]rune{{}},
+        gl: 0,
+        gr: 1,
+    }
+    // figlet's defaults: G0 is ASCII, G1 is the upper half of Latin-1
+    c.g[0] = charset_94('B')
+    c.g[1] = charset_96('A')
+    c.g[2] = charset_94('B')
+    c.g[3] = charset_94('B')
+
+    if r, err = unzip(r); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidControl, err)
+    }
+    scanner := bufio.NewScanner(r)
+    for scanner.Scan() {
+        line = scanner.Text()
+        lineno++
+        if lineno == 1 {
+            if !strings.HasPrefix(line, "flc2a") {
+                return nil, fmt.Errorf("%w: line 1: missing flc2a signature", ErrInvalidControl)
+            }
+            continue
+        }
+        if err = c.command(line); err != nil {
+            return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidControl, lineno, err)
+        }
+    }
+    if err = scanner.Err(); err != nil {
+        return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidControl, lineno+1, err)
+    }
+    if lineno == 0 {
+        return nil, fmt.Errorf("%w: empty file", ErrInvalidControl)
+    }
+    return &c, nil
+}
+
+// command applies one line of a control file
+func (c *FIGcontrol) command(line string) error {
+    var err error
+    var in, out []rune
+    var code int64
+
+    fields := strings.Fields(line)
+    if len(fields) == 0 || line[0] == '#' {
+        return nil
+    }
+
+    switch {
+        case line[0] == 't':
+            args := []rune(strings.TrimLeftFunc(line[1:], unicode.IsSpace))
+            if in, args, err = tchars(args); err != nil {
+                return err
+            }
+            args = []rune(strings.TrimLeftFunc(string(args), unicode.IsSpace))
+            if out, _, err = tchars(args); err != nil {
+                return err
+            }
+            if len(in) != len(out) && len(out) != 1 {
+                return errors.New("t: ranges differ in length")
+            }
+            for i := range in {
+                if len(out) == 1 {
+                    c.stage()[in[i]] = out[0]
+                } else {
+                    c.stage()[in[i]] = out[i]
+                }
+            }
+        case line[0] == '-' || (line[0] >= '0' && line[0] <= '9'):
+            // "number number", a single character translation
+            if len(fields) < 2 {
+                return errors.New("translation needs two codes")
+            }
+            var from, to rune
+            for i, s := range fields[:2] {
+                if code, err = strconv.ParseInt(s, 0, 32); err != nil {
+                    return err
+                }
+                if i == 0 {
+                    from = rune(code)
+                } else {
+                    to = rune(code)
+                }
+            }
+            c.stage()[from] = to
+        case fields[0] == "f":
+            // freeze: later commands apply to the output of earlier ones
+            c.stages = append(c.stages, map[rune]rune{})
+        case fields[0] == "b":
+            c.mode, c.moded = input_dbcs, true
+        case fields[0] == "u":
+            c.mode, c.moded = input_utf8, true
+        case fields[0] == "h":
+            c.mode, c.moded = input_hz, true
+        case fields[0] == "j":
+            c.mode, c.moded = input_shiftjis, true
+        case line[0] == 'g':
+            c.mode, c.moded = input_iso2022, true
+            return c.designate(fields)
+        default:
+            return fmt.Errorf("unknown command %q", fields[0])
+    }
+    return nil
+}
+
+// designate handles the ISO 2022 "g" commands:
+//
+//     g{0|1|2|3} {94|96|94x94} char
+//     g{L|R} {0|1|2|3}
+func (c *FIGcontrol) designate(fields []string) error {
+    if len(fields[0]) != 2 || len(fields) < 2 {
+        return fmt.Errorf("bad g command %q", strings.Join(fields, " "))
+    }
+    switch n := fields[0][1]; n {
+        case 'L', 'R':
+            if len(fields[1]) != 1 || fields[1][0] < '0' || fields[1][0] > '3' {
+                return fmt.Errorf("bad g%c set %q", n, fields[1])
+            }
+            if n == 'L' {
+                c.gl = int(fields[1][0] - '0')
+            } else {
+                c.gr = int(fields[1][0] - '0')
+            }
+        case '0', '1', '2', '3':
+            if len(fields) < 3 || len(fields[2]) != 1 {
+                return fmt.Errorf("bad g%c final character", n)
+            }
+            final := rune(fields[2][0])
+            switch fields[1] {
+                case "94":
+                    c.g[n-'0'] = charset_94(final)
+                case "96":
+                    c.g[n-'0'] = charset_96(final)
+                case "94x94":
+                    c.g[n-'0'] = charset{base: final<<16, double: true}
+                default:
+                    return fmt.Errorf("bad g%c set size %q", n, fields[1])
+            }
+        default:
+            return fmt.Errorf("bad g command %q", fields[0])
+    }
+    return nil
+}
+
+func charset_94(final rune) charset {
+    if final == 'B' {
+        // ASCII
+        return charset{}
+    }
+    return charset{base: final<<16}
+}
+
+func charset_96(final rune) charset {
+    if final == 'A' {
+        // upper half of Latin-1
+        return charset{base: 0x80}
+    }
+    return charset{base: final<<16 | 0x80}
+}
+
+// stage returns the translation table currently being built
+func (c *FIGcontrol) stage() map[rune]rune {
+    return c.stages[len(c.stages)-1]
+}
+
+// tchars reads a character or a range of characters for a "t" command,
+// returning them along with the unread input
+func tchars(s []rune) ([]rune, []rune, error) {
+    var lo, hi rune
+    var err error
+    var out []rune
+
+    if lo, s, err = tchar(s); err != nil {
+        return nil, nil, err
+    }
+    hi = lo
+    if len(s) > 1 && s[0] == '-' {
+        if hi, s, err = tchar(s[1:]); err != nil {
+            return nil, nil, err
+        }
+    }
+    if hi < lo || hi-lo > 0xffff {
+        return nil, nil, fmt.Errorf("bad range %q-%q", lo, hi)
+    }
+    for ; lo <= hi; lo++ {
+        out = append(out, lo)
+    }
+    return out, s, nil
+}
+
+// tchar reads one character, which may be escaped with "\" followed by a
+// decimal, octal or hex code, or by one of "abefnrtv"
+func tchar(s []rune) (rune, []rune, error) {
+    var i int
+
+    if len(s) == 0 {
+        return 0, nil, errors.New("missing character")
+    }
+    if s[0] != '\\' || len(s) == 1 {
+        return s[0], s[1:], nil
+    }
+    s = s[1:]
+    if s[0] == '-' || (s[0] >= '0' && s[0] <= '9') {
+        for i=1; i<len(s) && strings.ContainsRune("0123456789abcdefABCDEFxX", s[i]); i++ {
+        }
+        code, err := strconv.ParseInt(string(s[:i]), 0, 32)
+        if err != nil {
+            return 0, nil, err
+        }
+        return rune(code), s[i:], nil
+    }
+    switch s[0] {
+        case 'a': return 7, s[1:], nil
+        case 'b': return 8, s[1:], nil
+        case 'e': return 27, s[1:], nil
+        case 'f': return 12, s[1:], nil
+        case 'n': return 10, s[1:], nil
+        case 'r': return 13, s[1:], nil
+        case 't': return 9, s[1:], nil
+        case 'v': return 11, s[1:], nil
+    }
+    // "\\", "\ " and anything else stand for themselves
+    return s[0], s[1:], nil
+}
+
+// Decode s into character codes according to the control file's input
+// encoding.  Codes outside Unicode are possible, such as those figlet
+// assigns to ISO 2022 character sets.
+func (c *FIGcontrol) Decode(s string) []rune {
+    var out []rune
+    var i int
+    var b byte
+
+    switch c.mode {
+        case input_utf8:
+            return []rune(s)
+        case input_dbcs:
+            for i=0; i<len(s); i++ {
+                if s[i] >= 0x80 && i+1 < len(s) {
+                    out = append(out, rune(s[i])<<8 | rune(s[i+1]))
+                    i++
+                } else {
+                    out = append(out, rune(s[i]))
+                }
+            }
+        case input_shiftjis:
+            for i=0; i<len(s); i++ {
+                b = s[i]
+                if ((b >= 0x81 && b <= 0x9f) || (b >= 0xe0 && b <= 0xef)) && i+1 < len(s) {
+                    out = append(out, rune(b)<<8 | rune(s[i+1]))
+                    i++
+                } else {
+                    out = append(out, rune(b))
+                }
+            }
+        case input_hz:
+            // "~{" shifts into GB 2312, "~}" shifts back, "~~" is a tilde
+            gb := false
+            for i=0; i<len(s); i++ {
+                b = s[i]
+                if b == '~' && i+1 < len(s) {
+                    switch s[i+1] {
+                        case '{': gb = true; i++; continue
+                        case '}': gb = false; i++; continue
+                        case '~': out = append(out, '~'); i++; continue
+                        case '\n': i++; continue
+                    }
+                }
+                if gb && b > 0x20 && b < 0x7f && i+1 < len(s) {
+                    out = append(out, 'A'<<16 | rune(b)<<8 | rune(s[i+1]))
+                    i++
+                } else {
+                    out = append(out, rune(b))
+                }
+            }
+        case input_iso2022:
+            out = c.iso2022(s)
+    }
+    return out
+}
+
+// iso2022 decodes s using escape sequences and shifts to switch between the
+// character sets designated into G0..G3
+func (c *FIGcontrol) iso2022(s string) []rune {
+    var out []rune
+    var i, set, single int
+    var b byte
+
+    g, gl, gr := c.g, c.gl, c.gr
+    single = -1
+    for i=0; i<len(s); i++ {
+        b = s[i]
+        switch {
+            case b == 0x0e:
+                // SO, shift out
+                gl = 1
+                continue
+            case b == 0x0f:
+                // SI, shift in
+                gl = 0
+                continue
+            case b == 0x1b && i+1 < len(s):
+                i++
+                switch s[i] {
+                    case 'n': gl = 2
+                    case 'o': gl = 3
+                    case 'N': single = 2
+                    case 'O': single = 3
+                    case '(', ')', '*', '+':
+                        if i+1 < len(s) {
+                            g[s[i]-'('] = charset_94(rune(s[i+1]))
+                            i++
+                        }
+                    case '-', '.', '/':
+                        if i+1 < len(s) {
+                            g[s[i]-','] = charset_96(rune(s[i+1]))
+                            i++
+                        }
+                    case '$':
+                        // ESC $ F is G0, ESC $ ( F .. ESC $ + F are G0..G3
+                        n := 0
+                        if i+1 < len(s) && s[i+1] >= '(' && s[i+1] <= '+' {
+                            n = int(s[i+1] - '(')
+                            i++
+                        }
+                        if i+1 < len(s) {
+                            g[n] = charset{base: rune(s[i+1])<<16, double: true}
+                            i++
+                        }
+                }
+                continue
+            case b < 0x20 || b == 0x7f:
+                out = append(out, rune(b))
+                continue
+        }
+
+        set = gl
+        if b >= 0x80 {
+            set = gr
+        }
+        if single >= 0 {
+            set = single
+            single = -1
+        }
+        b &= 0x7f
+        if g[set].double {
+            if i+1 < len(s) {
+                out = append(out, g[set].base | rune(b)<<8 | rune(s[i+1]&0x7f))
+                i++
+            }
+            continue
+        }
+        out = append(out, g[set].base + rune(b))
+    }
+    return out
+}
+
+// Map a character through each stage of translations
+func (c *FIGcontrol) Map(r rune) rune {
+    for _, stage := range c.stages {
+        if t, ok := stage[r]; ok {
+            r = t
+        }
+    }
+    return r
+}
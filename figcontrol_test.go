@@ -0,0 +1,159 @@
+package main
+
+import (
+    "reflect"
+    "strings"
+    "testing"
+)
+
+// control parses a control file, without its flc2a signature line
+func control(t *testing.T, lines string) *FIGcontrol {
+    c, err := NewFIGcontrol(strings.NewReader("flc2a\n" + lines))
+    if err != nil {
+        t.Fatal(err)
+    }
+    return c
+}
+
+func TestTchar(t *testing.T) {
+    tests := []struct {
+        in    string
+        c     rune
+        rest  string
+    }{
+        {"a b", 'a', " b"},
+        {"\\65", 'A', ""},
+        {"\\0x41-", 'A', "-"},
+        {"\\0101", 'A', ""},  // octal
+        {"\\-1", -1, ""},
+        {"\\n", '\n', ""},
+        {"\\e", 27, ""},
+        {"\\ x", ' ', "x"},
+        {"\\\\", '\\', ""},
+        {"\\", '\\', ""},
+    }
+    for _, tt := range tests {
+        c, rest, err := tchar([]rune(tt.in))
+        if err != nil || c != tt.c || string(rest) != tt.rest {
+            t.Errorf("tchar(%q) = %q, %q, %v, want %q, %q", tt.in, c, string(rest), err, tt.c, tt.rest)
+        }
+    }
+    if _, _, err := tchar(nil); err == nil {
+        t.Error("tchar of nothing succeeded")
+    }
+}
+
+func TestTchars(t *testing.T) {
+    tests := []struct {
+        in    string
+        out   string
+        rest  string
+    }{
+        {"a", "a", ""},
+        {"a-e x", "abcde", " x"},
+        {"\\65-\\67", "ABC", ""},
+        {"a-", "a", "-"},  // a lone trailing "-" isn't a range
+    }
+    for _, tt := range tests {
+        out, rest, err := tchars([]rune(tt.in))
+        if err != nil || string(out) != tt.out || string(rest) != tt.rest {
+            t.Errorf("tchars(%q) = %q, %q, %v, want %q, %q", tt.in, string(out), string(rest), err, tt.out, tt.rest)
+        }
+    }
+    for _, in := range []string{"z-a", "\\0-\\0x20000", ""} {
+        if _, _, err := tchars([]rune(in)); err == nil {
+            t.Errorf("tchars(%q) succeeded", in)
+        }
+    }
+}
+
+func TestFIGcontrolMap(t *testing.T) {
+    tests := []struct {
+        name   string
+        lines  string
+        in     string
+        out    string
+    }{
+        {"range", "t a-z A-Z\n", "abc xyz", "ABC XYZ"},
+        {"one output", "t a-c _\n", "abcd", "___d"},
+        {"codes", "# comment\n0x41 0x61\n66 98\n", "ABC", "abC"},
+        // without a freeze the last translation of a character wins, and
+        // translations don't chain
+        {"no freeze", "t a b\nt b c\n", "ab", "bc"},
+        {"freeze", "t a b\nf\nt b c\n", "ab", "cc"},
+        {"swap", "t a b\nt b a\n", "ab", "ba"},
+        {"swap frozen", "t a b\nf\nt b a\n", "ab", "aa"},
+    }
+    for _, tt := range tests {
+        c := control(t, tt.lines)
+        var out []rune
+        for _, r := range tt.in {
+            out = append(out, c.Map(r))
+        }
+        if string(out) != tt.out {
+            t.Errorf("%s: %q maps to %q, want %q", tt.name, tt.in, string(out), tt.out)
+        }
+    }
+}
+
+func TestFIGcontrolErrors(t *testing.T) {
+    for _, text := range []string{
+        "",
+        "flc1a\n",
+        "flc2a\nt a\n",
+        "flc2a\nt a-c x-y\n",
+        "flc2a\n65\n",
+        "flc2a\ng4 94 B\n",
+        "flc2a\ng0 95 B\n",
+        "flc2a\nz\n",
+    } {
+        if _, err := NewFIGcontrol(strings.NewReader(text)); err == nil {
+            t.Errorf("NewFIGcontrol(%q) succeeded", text)
+        }
+    }
+}
+
+func TestISO2022(t *testing.T) {
+    tests := []struct {
+        name  string
+        in    string
+        out   []rune
+    }{
+        {"ASCII", "Ab", []rune{'A', 'b'}},
+        // ESC ( J designates JIS X 0201 Roman into G0
+        {"ESC ( F", "A\x1b(JA\x1b(BA", []rune{'A', 'J'<<16 | 'A', 'A'}},
+        // G1 is the upper half of Latin-1, by shift out or the high bit
+        {"shift out", "A\x0eA\x0fA", []rune{'A', 'Á', 'A'}},
+        {"high bit", "\xc1", []rune{'Á'}},
+        {"ESC $ B", "\x1b$B\x30\x21", []rune{'B'<<16 | 0x3021}},
+        {"single shift", "\x1b*J\x1bNAA", []rune{'J'<<16 | 'A', 'A'}},
+    }
+    c := control(t, "g0 94 B\n")
+    for _, tt := range tests {
+        if got := c.Decode(tt.in); !reflect.DeepEqual(got, tt.out) {
+            t.Errorf("%s: Decode(%q) = %U, want %U", tt.name, tt.in, got, tt.out)
+        }
+    }
+}
+
+func TestControlsInputMode(t *testing.T) {
+    tests := []struct {
+        name      string
+        controls  []string
+        out       []rune
+    }{
+        {"utf8", []string{"t a b\n"}, []rune{'b', 'é'}},
+        {"later g", []string{"t a b\n", "g0 94 J\n"}, []rune{'J'<<16 | 'a', 0xc3, 0xa9}},
+        {"later u", []string{"g0 94 J\n", "u\n"}, []rune{'a', 'é'}},
+        {"no mode after g", []string{"g0 94 J\n", "t a b\n"}, []rune{'J'<<16 | 'a', 0xc3, 0xa9}},
+    }
+    for _, tt := range tests {
+        f := &FIGfont{}
+        for _, lines := range tt.controls {
+            f.Controls = append(f.Controls, control(t, lines))
+        }
+        if got := f.translate("aé"); !reflect.DeepEqual(got, tt.out) {
+            t.Errorf("%s: translate = %U, want %U", tt.name, got, tt.out)
+        }
+    }
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
+    "archive/zip"
     "bufio"
+    "bytes"
     "errors"
+    "fmt"
     "io"
     "strconv"
     "strings"
+    "unicode/utf8"
+
+    "github.com/mattn/go-runewidth"
 )
 
 // See: figfont.txt
@@ -13,7 +19,8 @@ import (
 type FIGfont struct {
     Name       string
     Height     int
-    hardblank  byte
+    signature  string  // "flf2a" or "tlf2a"
+    hardblank  rune
     baseline   int
     maxlen     int
     oldlayout  int
@@ -21,7 +28,12 @@ type FIGfont struct {
     direction  int
     layout     int
     codetags   int
+    comment    []string
     chars      map[rune][]string
+    glyphs     map[rune]*figchar  // chars, ready for rendering
+
+    // control files applied, in order, to text before rendering
+    Controls   []*FIGcontrol
 }
 
 // Full layout
@@ -58,14 +70,47 @@ const (
 var ErrInvalidFont  = errors.New("invalid FIGfont")
 var ErrParse        = errors.New("couldn't parse FIGfont")
 
+// classes for smushing rule 3, lowest to highest
+var smush_hierarchy = []string{"|", `/\`, "[]", "{}", "()", "<>"}
+
 var charorder string = ` !"#$%&'()*+,-./` + `0123456789:;<=>?` + `@ABCDEFGHIJKLMNO` +
                        `PQRSTUVWXYZ[\]^_` + "`abcdefghijklmno" + "pqrstuvwxyz{|}~" +
-                       "\xc4\xd6\xdc\xe4\xf6\xfc\xdf"
+                       "\u00c4\u00d6\u00dc\u00e4\u00f6\u00fc\u00df"  // ÄÖÜäöüß
 
 func (f *FIGfont) String() string {
     return f.Name
 }
 
+// Baseline is the height of the FIGcharacters not counting descenders
+func (f *FIGfont) Baseline() int {
+    return f.baseline
+}
+
+// NumGlyphs is how many FIGcharacters the font has
+func (f *FIGfont) NumGlyphs() int {
+    return len(f.chars)
+}
+
+// LayoutMode describes how FIGcharacters are placed next to each other
+func (f *FIGfont) LayoutMode() string {
+    var rules []string
+
+    switch {
+        case f.layout & horizontal_smush == 0 && f.layout & horizontal_fit == 0:
+            return "full width"
+        case f.layout & horizontal_smush == 0:
+            return "fitting"
+        case f.layout & 0x3f == 0:
+            return "universal smushing"
+    }
+    for i:=0; i<6; i++ {
+        if f.layout & (1<<i) != 0 {
+            rules = append(rules, strconv.Itoa(i+1))
+        }
+    }
+    return "smushing rules " + strings.Join(rules, ",")
+}
+
 func NewFIGfont(r io.Reader) (*FIGfont, error) {
     var err error
     var lines, header []string
@@ -73,25 +118,45 @@ func NewFIGfont(r io.Reader) (*FIGfont, error) {
     var s string
     var i int
 
+    if r, err = unzip(r); err != nil {
+        return nil, fmt.Errorf("%w: %v", ErrInvalidFont, err)
+    }
     scanner := bufio.NewScanner(r)
     for scanner.Scan() {
         lines = append(lines, scanner.Text())
     }
+    if err = scanner.Err(); err != nil {
+        return nil, fmt.Errorf("%w: line %d: %v", ErrParse, len(lines)+1, err)
+    }
 
+    if len(lines) == 0 {
+        return nil, fmt.Errorf("%w: empty file", ErrInvalidFont)
+    }
     header = strings.Fields(lines[0])
-    if header[0][0:5] != "flf2a" {
-        return nil, ErrParse
+    // FIGfonts are "flf2a", TOIlet fonts are "tlf2a" with UTF-8 throughout
+    if len(header) == 0 || len(header[0]) < 6 || (header[0][0:5] != "flf2a" && header[0][0:5] != "tlf2a") {
+        return nil, fmt.Errorf("%w: line 1: missing flf2a or tlf2a signature", ErrInvalidFont)
     }
 
     for _, s = range header[1:] {
         if i, err = strconv.Atoi(s); err != nil {
-            return nil, err
+            return nil, fmt.Errorf("%w: line 1: %v", ErrParse, err)
         }
         params = append(params, i)
     }
+    // height, baseline, max length, old layout and comment lines are required
+    if len(params) < 5 {
+        return nil, fmt.Errorf("%w: line 1: header has %d of 5 required parameters", ErrInvalidFont, len(params))
+    }
+    if params[0] < 1 || params[0] > len(lines) {
+        return nil, fmt.Errorf("%w: line 1: height %d", ErrInvalidFont, params[0])
+    }
+    if params[4] < 0 || params[4] > len(lines) {
+        return nil, fmt.Errorf("%w: line 1: %d comment lines", ErrInvalidFont, params[4])
+    }
 
-    f := FIGfont{}
-    f.hardblank = header[0][5]
+    f := FIGfont{signature: header[0][0:5]}
+    f.hardblank, _ = utf8.DecodeRuneInString(header[0][5:])
 
     if len(params) > 0 {
         f.Height = params[0]
@@ -117,47 +182,853 @@ func NewFIGfont(r io.Reader) (*FIGfont, error) {
     if len(params) > 7 {
         f.codetags = params[7]
     }
+    if len(params) < 7 {
+        // no full layout, derive it from the old layout as figlet does
+        switch {
+            case f.oldlayout == old_horizontal_fit:
+                f.layout = horizontal_fit
+            case f.oldlayout == full_width_layout:
+                f.layout = 0
+            default:
+                f.layout = (f.oldlayout & 0x1f) | horizontal_smush
+        }
+    }
+
+    idx := 1 + f.comments
+    if idx <= len(lines) {
+        f.comment = append(f.comment, lines[1:idx]...)
+    }
 
+    // required FIGcharacters, in charorder
     f.chars = map[rune][]string{}
-    var idx int
-    var line, endmark string
-    for i, c := range charorder {
-        idx = 1 + f.comments + (i*f.Height)
-        endmark = lines[idx][ len(lines[idx])-1: ]
-        for j:=0; j<f.Height; j++ {
-            line = lines[idx+j]
-            f.chars[c] = append(f.chars[c], strings.TrimRight(line, endmark))
+    if need := idx + len([]rune(charorder))*f.Height; len(lines) < need {
+        return nil, fmt.Errorf("%w: line %d: truncated, need %d lines for the required FIGcharacters", ErrInvalidFont, len(lines), need)
+    }
+    for _, c := range charorder {
+        if f.chars[c], err = f.glyph(lines[idx:idx+f.Height], idx+1); err != nil {
+            return nil, err
+        }
+        idx += f.Height
+    }
+
+    // code-tagged FIGcharacters, each preceded by a line with its code
+    var code int64
+    for ; idx < len(lines); idx += f.Height {
+        fields := strings.Fields(lines[idx])
+        if len(fields) == 0 {
+            break
+        }
+        if code, err = strconv.ParseInt(fields[0], 0, 32); err != nil {
+            return nil, fmt.Errorf("%w: line %d: code tag: %v", ErrParse, idx+1, err)
+        }
+        if idx+1+f.Height > len(lines) {
+            return nil, fmt.Errorf("%w: line %d: truncated FIGcharacter %s", ErrInvalidFont, idx+1, fields[0])
+        }
+        idx++
+        if code == -1 {
+            // -1 is not a legal code
+            continue
+        }
+        if f.chars[rune(code)], err = f.glyph(lines[idx:idx+f.Height], idx+1); err != nil {
+            return nil, err
         }
     }
+    f.compile()
     return &f, nil
 }
 
-// very stupid renderer that does _not_ respect FIGlet's rules
+// a FIGcharacter prepared for rendering
+type figchar struct {
+    rows   [][]rune  // with hardblanks, for smushing
+    plain  [][]rune  // with hardblanks replaced, for full width layout
+    width  int
+}
+
+// compile prepares every FIGcharacter for rendering, so it isn't converted
+// on every call to Render
+func (f *FIGfont) compile() {
+    f.glyphs = make(map[rune]*figchar, len(f.chars))
+    for c, fig := range f.chars {
+        g := figchar{rows: make([][]rune, f.Height), plain: make([][]rune, f.Height)}
+        for i:=0; i<f.Height && i<len(fig); i++ {
+            g.rows[i] = []rune(fig[i])
+            g.plain[i] = []rune(strings.Replace(fig[i], string(f.hardblank), " ", -1))
+        }
+        g.width = len(g.rows[0])
+        f.glyphs[c] = &g
+    }
+}
+
+// unzip returns a reader for the first member of a zip archive, as figlet
+// distributes many fonts compressed, or r itself if it isn't one
+func unzip(r io.Reader) (io.Reader, error) {
+    var err error
+    var buf []byte
+    var z *zip.Reader
+
+    br := bufio.NewReader(r)
+    if magic, _ := br.Peek(4); string(magic) != "PK\x03\x04" {
+        return br, nil
+    }
+    if buf, err = io.ReadAll(br); err != nil {
+        return nil, err
+    }
+    if z, err = zip.NewReader(bytes.NewReader(buf), int64(len(buf))); err != nil {
+        return nil, err
+    }
+    if len(z.File) == 0 {
+        return nil, errors.New("empty zip archive")
+    }
+    return z.File[0].Open()
+}
+
+// glyph strips the endmarks from the lines of a FIGcharacter, lineno is the
+// line number of the first line for errors
+func (f *FIGfont) glyph(lines []string, lineno int) ([]string, error) {
+    var glyph []string
+    var endmark string
+
+    for i, line := range lines {
+        if len(line) == 0 {
+            return nil, fmt.Errorf("%w: line %d: missing endmark", ErrInvalidFont, lineno+i)
+        }
+        if endmark == "" {
+            _, n := utf8.DecodeLastRuneInString(line)
+            endmark = line[len(line)-n:]
+        }
+        glyph = append(glyph, strings.TrimRight(line, endmark))
+    }
+    return glyph, nil
+}
+
+// print directions for RenderOptions
+const (
+    DirectionFont = iota  // the font's own print direction
+    LeftToRight
+    RightToLeft
+)
+
+// justifications for RenderOptions
+const (
+    JustifyDefault = iota  // left, or right for right-to-left text
+    JustifyLeft
+    JustifyCenter
+    JustifyRight
+)
+
+type RenderOptions struct {
+    Direction  int
+    Justify    int
+    Width      int   // terminal cells to justify (and wrap) within, 0 for none
+    Wrap       bool  // break lines between words to fit Width
+    Trim       bool  // strip trailing blanks from each line
+}
+
+// Render s as a single line of FIGlet text, kerning or smushing adjacent
+// FIGcharacters according to the font's layout
 func (f *FIGfont) Render(s string) []string {
-    var out, fig []string
+    return f.RenderWith(s, RenderOptions{})
+}
+
+// RenderWrapped renders s as FIGlet text no wider than width, breaking
+// between words (or within a word too wide on its own) and stacking the
+// lines according to the font's vertical layout
+func (f *FIGfont) RenderWrapped(s string, width int) []string {
+    return f.RenderWith(s, RenderOptions{Width: width, Wrap: true})
+}
+
+// RenderWith renders s according to opts
+func (f *FIGfont) RenderWith(s string, opts RenderOptions) []string {
+    var lines []string
     var line string
-    var i int
+
+    for _, row := range f.compose(s, opts).rows {
+        line = strings.Replace(string(row), string(f.hardblank), " ", -1)
+        if opts.Trim {
+            line = strings.TrimRight(line, " ")
+        }
+        lines = append(lines, line)
+    }
+    return lines
+}
+
+// rendered output with hardblanks still in place, and for each character the
+// index of the FIGcharacter in the text that it came from, or -1
+type figout struct {
+    rows    [][]rune
+    owner   [][]int
+    glyphs  int  // number of FIGcharacters rendered
+}
+
+// owner of the character at row y, column x
+func (o figout) owner_at(x, y int) int {
+    if y < len(o.owner) && x < len(o.owner[y]) {
+        return o.owner[y][x]
+    }
+    return -1
+}
+
+// compose lays out s according to opts
+func (f *FIGfont) compose(s string, opts RenderOptions) figout {
+    var out figout
+    var line, word, try string
     var c rune
-    var ok bool
 
-    for i=0; i<f.Height; i++ {
-        out = append(out, "")
+    rtl, justify := f.layout_opts(opts)
+    add := func(line string) {
+        out = f.stack(out, f.justify(f.render(line, rtl, out.glyphs), justify, opts.Width))
+    }
+
+    if !opts.Wrap || opts.Width <= 0 {
+        add(s)
+    } else {
+        for _, word = range strings.Fields(s) {
+            try = word
+            if line != "" {
+                try = line + " " + word
+            }
+            if f.width(f.render(try, rtl, 0).rows) <= opts.Width {
+                line = try
+                continue
+            }
+            if line != "" {
+                add(line)
+            }
+            // word doesn't fit on a line by itself, break it up
+            line = ""
+            for _, c = range word {
+                if line != "" && f.width(f.render(line + string(c), rtl, 0).rows) > opts.Width {
+                    add(line)
+                    line = ""
+                }
+                line += string(c)
+            }
+        }
+        if line != "" || out.rows == nil {
+            add(line)
+        }
     }
+    return out
+}
+
+// layout_opts returns the print direction and justification opts asks for
+func (f *FIGfont) layout_opts(opts RenderOptions) (bool, int) {
+    rtl := f.direction == 1
+    switch opts.Direction {
+        case LeftToRight:
+            rtl = false
+        case RightToLeft:
+            rtl = true
+    }
+    justify := opts.Justify
+    if justify == JustifyDefault {
+        justify = JustifyLeft
+        if rtl {
+            justify = JustifyRight
+        }
+    }
+    return rtl, justify
+}
+
+// render s as a single line, numbering its FIGcharacters from base
+func (f *FIGfont) render(s string, rtl bool, base int) figout {
+    var out, glyph [][]rune
+    var fig *figchar
+    var starts, widths []int
+    var i, x, amt, prevw, curw int
+    var c rune
+    var ok bool
 
-    for _, c = range s {
+    out = make([][]rune, f.Height)
+    for _, c = range f.translate(s) {
         if c == 0 {
-            return out
+            break
         }
-        if fig, ok = f.chars[c]; !ok {
+        if fig, ok = f.glyphs[c]; !ok {
             continue
         }
-        for i=0; i<f.Height; i++ {
-            line = strings.Replace(fig[i], string([]byte{f.hardblank}), " ", -1)
-            out[i] += line
+        glyph, curw = fig.rows, fig.width
+        if rtl {
+            amt = f.smushamt(glyph, out, prevw, curw, rtl)
+            out = f.addchar(glyph, out, amt, prevw, curw, rtl)
+            // everything so far moves right to make room
+            for i = range starts {
+                starts[i] += curw - amt
+            }
+            starts = append(starts, 0)
+        } else {
+            amt = f.smushamt(out, glyph, prevw, curw, rtl)
+            starts = append(starts, len(out[0]) - amt)
+            out = f.addchar(out, glyph, amt, prevw, curw, rtl)
         }
+        widths = append(widths, curw)
+        prevw = curw
     }
-    // TODO: strip ' ' off the right
 
+    // where FIGcharacters overlap, the later one in the text owns the column
+    owner := make([]int, len(out[0]))
+    for x = range owner {
+        owner[x] = -1
+    }
+    for i = range starts {
+        for x=starts[i]; x<starts[i]+widths[i] && x<len(owner); x++ {
+            if x >= 0 {
+                owner[x] = base + i
+            }
+        }
+    }
+    o := figout{rows: out, owner: make([][]int, f.Height), glyphs: base + len(starts)}
+    for i = range o.owner {
+        o.owner[i] = owner
+    }
+    return o
+}
+
+// RenderInto renders s as a single line like RenderWith, but into buf,
+// reusing its rows.  opts.Wrap is ignored.  Once buf has grown large enough
+// nothing is allocated unless the font has control files.  Rows are only
+// valid until buf is reused.
+func (f *FIGfont) RenderInto(buf [][]rune, s string, opts RenderOptions) [][]rune {
+    var prevw, pad, i, n int
+
+    if cap(buf) < f.Height {
+        buf = make([][]rune, f.Height)
+    }
+    buf = buf[:f.Height]
+    for i = range buf {
+        buf[i] = buf[i][:0]
+    }
+    rtl, justify := f.layout_opts(opts)
+
+    add := func(c rune) bool {
+        if c == 0 {
+            return false
+        }
+        if g, ok := f.glyphs[c]; ok {
+            f.add_into(buf, g, prevw, rtl)
+            prevw = g.width
+        }
+        return true
+    }
+    if len(f.Controls) > 0 {
+        for _, c := range f.translate(s) {
+            if !add(c) {
+                break
+            }
+        }
+    } else {
+        for _, c := range s {
+            if !add(c) {
+                break
+            }
+        }
+    }
+
+    if f.layout & (horizontal_smush | horizontal_fit) != 0 {
+        for _, row := range buf {
+            for i = range row {
+                if row[i] == f.hardblank {
+                    row[i] = ' '
+                }
+            }
+        }
+    }
+
+    // the same as justify, shifting the rows in place
+    switch justify {
+        case JustifyCenter:
+            pad = (opts.Width - f.width(buf)) / 2
+        case JustifyRight:
+            pad = opts.Width - f.width(buf)
+    }
+    for i = range buf {
+        if pad > 0 {
+            n = len(buf[i])
+            for len(buf[i]) < n + pad {
+                buf[i] = append(buf[i], ' ')
+            }
+            copy(buf[i][pad:], buf[i][:n])
+            for k := range buf[i][:pad] {
+                buf[i][k] = ' '
+            }
+        }
+        if opts.Trim {
+            for len(buf[i]) > 0 && buf[i][len(buf[i])-1] == ' ' {
+                buf[i] = buf[i][:len(buf[i])-1]
+            }
+        }
+    }
+    return buf
+}
+
+// add_into adds g to buf in place, the same way addchar joins them
+func (f *FIGfont) add_into(buf [][]rune, g *figchar, prevw int, rtl bool) {
+    var amt, row, col, k, n int
+    var l, r []rune
+
+    if f.layout & (horizontal_smush | horizontal_fit) == 0 {
+        // full width, nothing to smush
+        for row = range buf {
+            if rtl {
+                buf[row] = prepend(buf[row], g.plain[row], 0)
+            } else {
+                buf[row] = append(buf[row], g.plain[row]...)
+            }
+        }
+        return
+    }
+
+    if rtl {
+        // right-to-left: the glyph is on the left, smushed into the line
+        amt = f.smushamt(g.rows, buf, prevw, g.width, true)
+        for row = range buf {
+            l, r = g.rows[row], buf[row]
+            col = len(l) - amt
+            for k=0; k<amt && k<len(r); k++ {
+                if col+k >= 0 {
+                    r[k] = f.smushem(l[col+k], r[k], prevw, g.width, true)
+                }
+            }
+            // overlapped columns with nothing to smush into are dropped
+            n = 0
+            if col < 0 {
+                n = -col
+            }
+            if n > len(r) {
+                n = len(r)
+            }
+            if col < 0 {
+                col = 0
+            }
+            buf[row] = prepend(r, l[:col], n)
+        }
+        return
+    }
+
+    amt = f.smushamt(buf, g.rows, prevw, g.width, false)
+    for row = range buf {
+        l, r = buf[row], g.rows[row]
+        col = len(l) - amt
+        for k=0; k<amt && k<len(r); k++ {
+            if col+k >= 0 {
+                l[col+k] = f.smushem(l[col+k], r[k], prevw, g.width, false)
+            }
+        }
+        if amt > len(r) {
+            // overlapped columns with nothing to smush into are dropped
+            n = col + len(r)
+            if n < 0 {
+                n = 0
+            }
+            l = l[:n]
+        }
+        if amt < len(r) {
+            l = append(l, r[amt:]...)
+        }
+        buf[row] = l
+    }
+}
+
+// prepend returns p followed by row[skip:], reusing row's storage
+func prepend(row, p []rune, skip int) []rune {
+    n := len(row) - skip
+    row = append(row, p...)
+    copy(row[len(p):], row[skip:skip+n])
+    copy(row, p)
+    return row[:len(p)+n]
+}
+
+// translate s through the font's control files
+func (f *FIGfont) translate(s string) []rune {
+    var runes []rune
+
+    if len(f.Controls) == 0 {
+        return []rune(s)
+    }
+    // as in figlet, the last file to choose an input encoding wins
+    dec := f.Controls[0]
+    for _, c := range f.Controls {
+        if c.moded {
+            dec = c
+        }
+    }
+    runes = dec.Decode(s)
+    for _, c := range f.Controls {
+        for i := range runes {
+            runes[i] = c.Map(runes[i])
+        }
+    }
+    return runes
+}
+
+// justify pads the rows of out on the left to justify them within width
+func (f *FIGfont) justify(out figout, justify, width int) figout {
+    var pad, i int
+
+    switch justify {
+        case JustifyCenter:
+            pad = (width - f.width(out.rows)) / 2
+        case JustifyRight:
+            pad = width - f.width(out.rows)
+    }
+    if pad <= 0 {
+        return out
+    }
+    blank := []rune(strings.Repeat(" ", pad))
+    none := make([]int, pad)
+    for i = range none {
+        none[i] = -1
+    }
+    for i = range out.rows {
+        out.rows[i] = append(blank[:pad:pad], out.rows[i]...)
+        out.owner[i] = append(none[:pad:pad], out.owner[i]...)
+    }
+    return out
+}
+
+// width of the widest row of rendered output, in terminal cells
+func (f *FIGfont) width(out [][]rune) int {
+    var w, rw int
+
+    for _, row := range out {
+        rw = 0
+        for _, c := range row {
+            if c == f.hardblank || (c >= 0x20 && c < 0x7f) {
+                // printable ASCII is one cell, no need to look it up
+                rw++
+            } else {
+                rw += runewidth.RuneWidth(c)
+            }
+        }
+        if rw > w {
+            w = rw
+        }
+    }
+    return w
+}
+
+// smushamt returns how many columns the FIGcharacters in right can be moved
+// left into those in left, mirroring smushamt() in figlet.c.  The newly
+// added FIGcharacter is right, or left when printing right-to-left.
+func (f *FIGfont) smushamt(left, right [][]rune, prevw, curw int, rtl bool) int {
+    var row, linebd, charbd, amt, maxsmush int
+    var ch1, ch2 rune
+
+    if f.layout & (horizontal_smush | horizontal_fit) == 0 {
+        return 0
+    }
+    maxsmush = curw
+    for row=0; row<f.Height; row++ {
+        if rtl && maxsmush > len(right[row]) {
+            // never smush past the start of the line
+            maxsmush = len(right[row])
+        }
+        linebd = len(left[row])
+        ch1 = 0
+        for linebd > 0 && (ch1 == 0 || ch1 == ' ') {
+            linebd--
+            ch1 = left[row][linebd]
+        }
+        charbd = 0
+        for charbd < len(right[row]) && right[row][charbd] == ' ' {
+            charbd++
+        }
+        ch2 = 0
+        if charbd < len(right[row]) {
+            ch2 = right[row][charbd]
+        }
+
+        amt = charbd + len(left[row]) - 1 - linebd
+        if ch1 == 0 || ch1 == ' ' {
+            amt++
+        } else if ch2 != 0 && f.smushem(ch1, ch2, prevw, curw, rtl) != 0 {
+            amt++
+        }
+        if amt < maxsmush {
+            maxsmush = amt
+        }
+    }
+    return maxsmush
+}
+
+// addchar joins left and right, overlapping amt columns of each row.  As in
+// figlet, overlapped columns with nothing to smush into are dropped.
+func (f *FIGfont) addchar(left, right [][]rune, amt, prevw, curw int, rtl bool) [][]rune {
+    var row, k, col int
+    var l, r, joined []rune
+
+    out := make([][]rune, f.Height)
+    for row=0; row<f.Height; row++ {
+        l, r = left[row], right[row]
+        joined = make([]rune, 0, len(l) + len(r))
+        col = len(l) - amt
+        if col > 0 {
+            joined = append(joined, l[:col]...)
+        }
+        for k=0; k<amt && k<len(r); k++ {
+            if col+k >= 0 {
+                joined = append(joined, f.smushem(l[col+k], r[k], prevw, curw, rtl))
+            }
+        }
+        if amt < len(r) {
+            joined = append(joined, r[amt:]...)
+        }
+        out[row] = joined
+    }
+    return out
+}
+
+// smushem returns the character that results from smushing lch into rch,
+// or 0 if they can't be smushed.  See "SMUSHING RULES" in figfont.txt.
+func (f *FIGfont) smushem(lch, rch rune, prevw, curw int, rtl bool) rune {
+    hardblank := f.hardblank
+
+    if lch == ' ' {
+        return rch
+    }
+    if rch == ' ' {
+        return lch
+    }
+    // no overlapping if either FIGcharacter is a single column wide
+    if prevw < 2 || curw < 2 {
+        return 0
+    }
+    if f.layout & horizontal_smush == 0 {
+        // kerning only
+        return 0
+    }
+
+    if f.layout & 0x3f == 0 {
+        // universal smushing, preferring the visible character
+        if lch == hardblank {
+            return rch
+        }
+        if rch == hardblank {
+            return lch
+        }
+        // the later FIGcharacter in the text wins
+        if rtl {
+            return lch
+        }
+        return rch
+    }
+
+    // rule 6: hardblank smushing
+    if f.layout & horizontal_smush_6 != 0 {
+        if lch == hardblank && rch == hardblank {
+            return lch
+        }
+    }
+    if lch == hardblank || rch == hardblank {
+        return 0
+    }
+    // rule 1: equal character smushing
+    if f.layout & horizontal_smush_1 != 0 {
+        if lch == rch {
+            return lch
+        }
+    }
+    // rule 2: underscore smushing
+    if f.layout & horizontal_smush_2 != 0 {
+        if lch == '_' && strings.ContainsRune(`|/\[]{}()<>`, rch) {
+            return rch
+        }
+        if rch == '_' && strings.ContainsRune(`|/\[]{}()<>`, lch) {
+            return lch
+        }
+    }
+    // rule 3: hierarchy smushing, the character from the later class wins
+    if f.layout & horizontal_smush_3 != 0 {
+        lc, rc := smush_class(lch), smush_class(rch)
+        if lc >= 0 && rc >= 0 && lc != rc {
+            if lc < rc {
+                return rch
+            }
+            return lch
+        }
+    }
+    // rule 4: opposite pair smushing
+    if f.layout & horizontal_smush_4 != 0 {
+        switch string([]rune{lch, rch}) {
+            case "[]", "][", "{}", "}{", "()", ")(":
+                return '|'
+        }
+    }
+    // rule 5: big X smushing
+    if f.layout & horizontal_smush_5 != 0 {
+        switch string([]rune{lch, rch}) {
+            case `/\`:
+                return '|'
+            case `\/`:
+                return 'Y'
+            case "><":
+                return 'X'
+        }
+    }
+    return 0
+}
+
+// smush_class returns the index of c's hierarchy class, or -1
+func smush_class(c rune) int {
+    for i, class := range smush_hierarchy {
+        if strings.ContainsRune(class, c) {
+            return i
+        }
+    }
+    return -1
+}
+
+// stack appends the rows of bot below top, overlapping them as far as the
+// font's vertical layout allows
+func (f *FIGfont) stack(top, bot figout) figout {
+    var out figout
+    var amt, i, j, n int
+
+    if len(top.rows) == 0 {
+        return bot
+    }
+    amt = f.vsmushamt(top.rows, bot.rows)
+    n = len(top.rows) - amt
+    out.rows = append(out.rows, top.rows[:n]...)
+    out.owner = append(out.owner, top.owner[:n]...)
+    for i=0; i<amt; i++ {
+        t, b := top.rows[n+i], bot.rows[i]
+        row := make([]rune, len(t))
+        if len(b) > len(t) {
+            row = make([]rune, len(b))
+        }
+        owner := make([]int, len(row))
+        for j=0; j<len(row); j++ {
+            row[j] = f.vsmushem(at(t, j), at(b, j))
+            owner[j] = top.owner_at(j, n+i)
+            if at(b, j) != ' ' && row[j] == at(b, j) {
+                owner[j] = bot.owner_at(j, i)
+            }
+        }
+        out.rows = append(out.rows, row)
+        out.owner = append(out.owner, owner)
+    }
+    out.rows = append(out.rows, bot.rows[amt:]...)
+    out.owner = append(out.owner, bot.owner[amt:]...)
+    out.glyphs = bot.glyphs
     return out
 }
 
+// vsmushamt returns how many rows of bot can overlap the bottom of top.
+// Rows overlap while every column fits, and with smushing one row pair
+// may also be smushed together.
+func (f *FIGfont) vsmushamt(top, bot [][]rune) int {
+    var amt, i, j, smushed int
+    var t, b []rune
+    var tc, bc rune
+
+    if f.layout & (vertical_smush | vertical_fit) == 0 {
+        return 0
+    }
+    overlap:
+    for amt=1; amt<=len(top) && amt<=len(bot); amt++ {
+        smushed = 0
+        for i=0; i<amt; i++ {
+            t, b = top[len(top)-amt+i], bot[i]
+            fit, super := true, true
+            for j=0; j<len(t) || j<len(b); j++ {
+                tc, bc = at(t, j), at(b, j)
+                if tc == ' ' || bc == ' ' {
+                    continue
+                }
+                if f.vsmushem(tc, bc) == 0 {
+                    break overlap
+                }
+                fit = false
+                if tc != '|' || bc != '|' || f.layout & vertical_smush_5 == 0 {
+                    super = false
+                }
+            }
+            if !fit && !super {
+                smushed++
+            }
+        }
+        if smushed > 1 {
+            break
+        }
+    }
+    return amt-1
+}
+
+// vsmushem returns the character that results from smushing top above bot,
+// or 0 if they can't be smushed
+func (f *FIGfont) vsmushem(top, bot rune) rune {
+    hardblank := f.hardblank
+
+    if top == ' ' {
+        return bot
+    }
+    if bot == ' ' {
+        return top
+    }
+    if f.layout & vertical_smush == 0 {
+        // fitting only
+        return 0
+    }
+
+    if f.layout & 0x1f00 == 0 {
+        // universal smushing, preferring the visible character
+        if top == hardblank {
+            return bot
+        }
+        if bot == hardblank {
+            return top
+        }
+        return bot
+    }
+
+    if top == hardblank || bot == hardblank {
+        return 0
+    }
+    // rule 1: equal character smushing
+    if f.layout & vertical_smush_1 != 0 {
+        if top == bot {
+            return top
+        }
+    }
+    // rule 2: underscore smushing
+    if f.layout & vertical_smush_2 != 0 {
+        if top == '_' && strings.ContainsRune(`|/\[]{}()<>`, bot) {
+            return bot
+        }
+        if bot == '_' && strings.ContainsRune(`|/\[]{}()<>`, top) {
+            return top
+        }
+    }
+    // rule 3: hierarchy smushing
+    if f.layout & vertical_smush_3 != 0 {
+        tc, bc := smush_class(top), smush_class(bot)
+        if tc >= 0 && bc >= 0 && tc != bc {
+            if tc < bc {
+                return bot
+            }
+            return top
+        }
+    }
+    // rule 4: horizontal line smushing
+    if f.layout & vertical_smush_4 != 0 {
+        if (top == '-' && bot == '_') || (top == '_' && bot == '-') {
+            return '='
+        }
+    }
+    // rule 5: vertical line supersmushing
+    if f.layout & vertical_smush_5 != 0 {
+        if top == '|' && bot == '|' {
+            return '|'
+        }
+    }
+    return 0
+}
+
+// at returns row[i], or a blank past the end of row
+func at(row []rune, i int) rune {
+    if i < len(row) {
+        return row[i]
+    }
+    return ' '
+}
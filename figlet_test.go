@@ -0,0 +1,211 @@
+package main
+
+import (
+    "bytes"
+    "errors"
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+// the text rendered, in order, in each testdata/<font>.<layout>.<dir>.txt
+// file.  The files hold figlet 2.2.5's output with the hardblanks replaced by
+// spaces, one rendering of Height lines after another.
+var golden_text = []string{
+    "Hi 88.1",
+    "KQED",
+    "gofm",
+    "The quick brown fox",
+    "[]{}()<>/\\|_",
+    "a_b|c/d",
+    "W-Y 1.23",
+    "!@#$%^&*",
+}
+
+func TestRenderGolden(t *testing.T) {
+    tests := []struct {
+        font    string
+        name    string  // the layout part of the golden file's name
+        layout  int     // -1 for the font's own
+        dir     int
+    }{
+        // univers' old layout 32 becomes universal smushing
+        {"univers", "font", -1, LeftToRight},
+        {"univers", "font", -1, RightToLeft},
+        {"univers", "kern", horizontal_fit, LeftToRight},
+        {"univers", "full", 0, LeftToRight},
+        {"univers", "rules", horizontal_smush | 63, LeftToRight},
+        // nancyj-improved's old layout -1 is full width
+        {"nancyj-improved", "font", -1, LeftToRight},
+        {"nancyj-improved", "font", -1, RightToLeft},
+        {"nancyj-improved", "kern", horizontal_fit, LeftToRight},
+        {"nancyj-improved", "kern", horizontal_fit, RightToLeft},
+        {"nancyj-improved", "full", 0, LeftToRight},
+        {"nancyj-improved", "rules", horizontal_smush | 63, LeftToRight},
+    }
+
+    for _, tt := range tests {
+        dir := "ltr"
+        if tt.dir == RightToLeft {
+            dir = "rtl"
+        }
+        file := filepath.Join("testdata", tt.font + "." + tt.name + "." + dir + ".txt")
+        t.Run(tt.font + "." + tt.name + "." + dir, func(t *testing.T) {
+            f, err := LoadFont(tt.font)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if tt.layout >= 0 {
+                f.layout = tt.layout
+            }
+            want := golden(t, file, f.Height)
+            for i, s := range golden_text {
+                got := f.RenderWith(s, RenderOptions{Direction: tt.dir})
+                if strings.Join(got, "\n") != strings.Join(want[i], "\n") {
+                    t.Errorf("Render(%q):\n%s\nwant:\n%s", s, strings.Join(got, "\n"), strings.Join(want[i], "\n"))
+                }
+            }
+        })
+    }
+}
+
+func TestLayoutFromOldLayout(t *testing.T) {
+    tests := []struct {
+        font    string
+        layout  int
+    }{
+        {"univers", horizontal_smush},   // old layout 32
+        {"nancyj-improved", 0},          // old layout -1
+    }
+    for _, tt := range tests {
+        f, err := LoadFont(tt.font)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if f.layout != tt.layout {
+            t.Errorf("%s: layout %d, want %d", tt.font, f.layout, tt.layout)
+        }
+    }
+}
+
+// golden reads a golden file as one rendering per golden_text
+func golden(t *testing.T, file string, height int) [][]string {
+    var out [][]string
+
+    b, err := os.ReadFile(file)
+    if err != nil {
+        t.Fatal(err)
+    }
+    lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+    if len(lines) != height * len(golden_text) {
+        t.Fatalf("%s: %d lines, want %d", file, len(lines), height * len(golden_text))
+    }
+    for i := 0; i < len(lines); i += height {
+        out = append(out, lines[i:i+height])
+    }
+    return out
+}
+
+func FuzzNewFIGfont(f *testing.F) {
+    for _, name := range []string{"univers.flf", "nancyj-improved.flf"} {
+        b, err := embedded.ReadFile(name)
+        if err != nil {
+            f.Fatal(err)
+        }
+        f.Add(b)
+    }
+    f.Add([]byte(""))                                // empty file
+    f.Add([]byte("flf2"))                            // header under 5 bytes
+    f.Add([]byte("flf2a$ 4 3 10 0 0\n"))             // too few lines
+    f.Add([]byte("flf2a$ 2 1 10 0 0\n @\n\n"))       // empty glyph line
+
+    f.Fuzz(func(t *testing.T, b []byte) {
+        font, err := NewFIGfont(bytes.NewReader(b))
+        if err != nil {
+            if !errors.Is(err, ErrInvalidFont) && !errors.Is(err, ErrParse) {
+                t.Fatalf("error doesn't wrap ErrInvalidFont or ErrParse: %v", err)
+            }
+            return
+        }
+        font.Render("Hello, gofm!")
+        font.RenderWith("Hello, gofm!", RenderOptions{Direction: RightToLeft, Width: 20, Wrap: true})
+    })
+}
+
+func TestRenderInto(t *testing.T) {
+    var buf [][]rune
+
+    opts := []RenderOptions{
+        {},
+        {Direction: RightToLeft},
+        {Justify: JustifyCenter, Width: 60},
+        {Direction: RightToLeft, Justify: JustifyCenter, Width: 60, Trim: true},
+        {Justify: JustifyRight, Width: 200, Trim: true},
+    }
+    for _, name := range []string{"univers", "nancyj-improved"} {
+        f, err := LoadFont(name)
+        if err != nil {
+            t.Fatal(err)
+        }
+        for _, layout := range []int{f.layout, horizontal_fit, 0, horizontal_smush | 63} {
+            f.layout = layout
+            for _, o := range opts {
+                for _, s := range golden_text {
+                    // buf is reused across renderings, as it would be
+                    buf = f.RenderInto(buf, s, o)
+                    got := make([]string, len(buf))
+                    for i := range buf {
+                        got[i] = string(buf[i])
+                    }
+                    if want := f.RenderWith(s, o); !reflect.DeepEqual(got, want) {
+                        t.Errorf("%s, layout %d, %+v: RenderInto(%q):\n%s\nwant:\n%s", name, layout, o, s,
+                            strings.Join(got, "\n"), strings.Join(want, "\n"))
+                    }
+                }
+            }
+        }
+    }
+}
+
+func TestRenderIntoAllocs(t *testing.T) {
+    for _, name := range []string{"univers", "nancyj-improved"} {
+        f, err := LoadFont(name)
+        if err != nil {
+            t.Fatal(err)
+        }
+        opts := RenderOptions{Justify: JustifyCenter, Width: 60}
+        buf := f.RenderInto(nil, "107.9 KQED", opts)
+        allocs := testing.AllocsPerRun(100, func() {
+            buf = f.RenderInto(buf, "88.1 WXYZ", opts)
+        })
+        if allocs != 0 {
+            t.Errorf("%s: RenderInto allocates %v times per run", name, allocs)
+        }
+    }
+}
+
+func BenchmarkRender(b *testing.B) {
+    f, err := LoadFont("univers")
+    if err != nil {
+        b.Fatal(err)
+    }
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        f.RenderWith("107.9", RenderOptions{Justify: JustifyCenter, Width: 60})
+    }
+}
+
+func BenchmarkRenderInto(b *testing.B) {
+    var buf [][]rune
+
+    f, err := LoadFont("univers")
+    if err != nil {
+        b.Fatal(err)
+    }
+    b.ReportAllocs()
+    for i := 0; i < b.N; i++ {
+        buf = f.RenderInto(buf, "107.9", RenderOptions{Justify: JustifyCenter, Width: 60})
+    }
+}
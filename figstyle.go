@@ -0,0 +1,175 @@
+package main
+
+import (
+    "image/color"
+    "math"
+    "strconv"
+    "strings"
+)
+
+// Cell is one character of styled FIGlet output
+type Cell struct {
+    Rune  rune
+    Fg    color.Color  // nil for the terminal's default
+    Bg    color.Color
+}
+
+// Filter picks the color of the character at column x, row y of a w by h
+// rendering.  glyph is the index of the FIGcharacter in the text that the
+// character came from, or -1 for padding.
+type Filter func(x, y, glyph, w, h int) color.Color
+
+// RenderStyled renders s according to opts, coloring the visible characters
+// with filter
+func (f *FIGfont) RenderStyled(s string, opts RenderOptions, filter Filter) [][]Cell {
+    var cells [][]Cell
+    var row []Cell
+    var x, y, w int
+    var c rune
+
+    out := f.compose(s, opts)
+    w = f.width(out.rows)
+    for y = range out.rows {
+        row = make([]Cell, 0, len(out.rows[y]))
+        for x, c = range out.rows[y] {
+            if c == f.hardblank {
+                c = ' '
+            }
+            cell := Cell{Rune: c}
+            if c != ' ' && filter != nil {
+                cell.Fg = filter(x, y, out.owner_at(x, y), w, len(out.rows))
+            }
+            row = append(row, cell)
+        }
+        if opts.Trim {
+            for len(row) > 0 && row[len(row)-1].Rune == ' ' && row[len(row)-1].Bg == nil {
+                row = row[:len(row)-1]
+            }
+        }
+        cells = append(cells, row)
+    }
+    return cells
+}
+
+// Rainbow cycles through the hues diagonally, once every period columns,
+// like TOIlet's "gay" filter
+func Rainbow(period int) Filter {
+    if period < 1 {
+        period = 1
+    }
+    return func(x, y, glyph, w, h int) color.Color {
+        return hue(float64((x + y) % period) / float64(period))
+    }
+}
+
+// Gradient blends from one color to another across the width
+func Gradient(from, to color.Color) Filter {
+    return func(x, y, glyph, w, h int) color.Color {
+        if w < 2 {
+            return from
+        }
+        return blend(from, to, float64(x) / float64(w-1))
+    }
+}
+
+// Metal shades each row from light blue down to dark gray, like TOIlet's
+// "metal" filter
+func Metal() Filter {
+    palette := []color.Color{
+        color.RGBA{0x55, 0x55, 0xff, 0xff},  // light blue
+        color.RGBA{0x00, 0x00, 0xaa, 0xff},  // blue
+        color.RGBA{0xaa, 0xaa, 0xaa, 0xff},  // light gray
+        color.RGBA{0x55, 0x55, 0x55, 0xff},  // dark gray
+    }
+    return func(x, y, glyph, w, h int) color.Color {
+        return palette[(y * len(palette) / h + x / 8) % len(palette)]
+    }
+}
+
+// Glyphs colors each FIGcharacter of the text in turn from colors
+func Glyphs(colors ...color.Color) Filter {
+    return func(x, y, glyph, w, h int) color.Color {
+        if glyph < 0 || len(colors) == 0 {
+            return nil
+        }
+        return colors[glyph % len(colors)]
+    }
+}
+
+// ANSI formats cells as lines of text with 24-bit color escape sequences
+func ANSI(cells [][]Cell) string {
+    var b strings.Builder
+    var fg, bg color.Color
+
+    for _, row := range cells {
+        fg, bg = nil, nil
+        for _, cell := range row {
+            if cell.Rune != ' ' && !same_color(cell.Fg, fg) {
+                b.WriteString(sgr(cell.Fg, "38", "39"))
+                fg = cell.Fg
+            }
+            if !same_color(cell.Bg, bg) {
+                b.WriteString(sgr(cell.Bg, "48", "49"))
+                bg = cell.Bg
+            }
+            b.WriteRune(cell.Rune)
+        }
+        if fg != nil || bg != nil {
+            b.WriteString("\x1b[0m")
+        }
+        b.WriteByte('\n')
+    }
+    return b.String()
+}
+
+// sgr returns the escape sequence selecting c, or the default color if c
+// is nil
+func sgr(c color.Color, set, reset string) string {
+    if c == nil {
+        return "\x1b[" + reset + "m"
+    }
+    r, g, b := rgb(c)
+    return "\x1b[" + set + ";2;" + strconv.Itoa(r) + ";" + strconv.Itoa(g) + ";" + strconv.Itoa(b) + "m"
+}
+
+// rgb returns the 8-bit components of c
+func rgb(c color.Color) (int, int, int) {
+    r, g, b, _ := c.RGBA()
+    return int(r >> 8), int(g >> 8), int(b >> 8)
+}
+
+func same_color(a, b color.Color) bool {
+    if a == nil || b == nil {
+        return a == nil && b == nil
+    }
+    ar, ag, ab := rgb(a)
+    br, bg, bb := rgb(b)
+    return ar == br && ag == bg && ab == bb
+}
+
+// blend returns the color t of the way from a to b
+func blend(a, b color.Color, t float64) color.Color {
+    ar, ag, ab := rgb(a)
+    br, bg, bb := rgb(b)
+    mix := func(x, y int) uint8 {
+        return uint8(math.Round(float64(x) + (float64(y) - float64(x)) * t))
+    }
+    return color.RGBA{mix(ar, br), mix(ag, bg), mix(ab, bb), 0xff}
+}
+
+// hue returns the fully saturated color at h (0..1) around the color wheel
+func hue(h float64) color.Color {
+    var r, g, b float64
+
+    h = math.Mod(h, 1) * 6
+    x := 1 - math.Abs(math.Mod(h, 2) - 1)
+    switch int(h) {
+        case 0: r, g, b = 1, x, 0
+        case 1: r, g, b = x, 1, 0
+        case 2: r, g, b = 0, 1, x
+        case 3: r, g, b = 0, x, 1
+        case 4: r, g, b = x, 0, 1
+        default: r, g, b = 1, 0, x
+    }
+    return color.RGBA{uint8(r * 255), uint8(g * 255), uint8(b * 255), 0xff}
+}
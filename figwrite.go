@@ -0,0 +1,119 @@
+package main
+
+import (
+    "bytes"
+    "fmt"
+    "io"
+    "sort"
+    "strings"
+    "unicode"
+    "unicode/utf8"
+)
+
+// WriteTo writes f as a font file: the header, comments, the required
+// FIGcharacters and then any code-tagged FIGcharacters, in code order
+func (f *FIGfont) WriteTo(w io.Writer) (int64, error) {
+    var buf bytes.Buffer
+    var codes []int
+    var maxlen int
+
+    required := map[rune]bool{}
+    for _, c := range charorder {
+        required[c] = true
+    }
+    for c, glyph := range f.chars {
+        if !required[c] {
+            codes = append(codes, int(c))
+        }
+        for _, row := range glyph {
+            // room for the endmarks
+            if n := utf8.RuneCountInString(row) + 2; n > maxlen {
+                maxlen = n
+            }
+        }
+    }
+    sort.Ints(codes)
+    if f.maxlen > maxlen {
+        maxlen = f.maxlen
+    }
+
+    signature := f.signature
+    if signature == "" {
+        signature = "flf2a"
+    }
+    if f.hardblank >= utf8.RuneSelf {
+        // only TOIlet fonts may have a multi-byte hardblank
+        signature = "tlf2a"
+    }
+    fmt.Fprintf(&buf, "%s%c %d %d %d %d %d %d %d %d\n", signature, f.hardblank,
+        f.Height, f.baseline, maxlen, f.oldlayout, len(f.comment), f.direction, f.layout, len(codes))
+    for _, line := range f.comment {
+        buf.WriteString(line)
+        buf.WriteByte('\n')
+    }
+
+    for _, c := range charorder {
+        f.write_glyph(&buf, f.chars[c])
+    }
+    for _, code := range codes {
+        if code < 0 {
+            fmt.Fprintf(&buf, "%d\n", code)
+        } else {
+            fmt.Fprintf(&buf, "0x%04X\n", code)
+        }
+        f.write_glyph(&buf, f.chars[rune(code)])
+    }
+
+    n, err := w.Write(buf.Bytes())
+    return int64(n), err
+}
+
+// write_glyph writes the rows of a FIGcharacter with endmarks, doubling
+// the endmark on the last row.  Missing rows are written empty.
+func (f *FIGfont) write_glyph(buf *bytes.Buffer, glyph []string) {
+    var row string
+
+    end := string(f.endmark(glyph))
+    for i:=0; i<f.Height; i++ {
+        row = ""
+        if i < len(glyph) {
+            row = glyph[i]
+        }
+        buf.WriteString(row)
+        buf.WriteString(end)
+        if i == f.Height-1 {
+            buf.WriteString(end)
+        }
+        buf.WriteByte('\n')
+    }
+}
+
+// endmark picks an endmark for glyph.  It can't be the last character of any
+// row, or it would be stripped along with the endmarks when the font is read
+// back, so if all the usual ones are taken any other printable character is
+// used.
+func (f *FIGfont) endmark(glyph []string) rune {
+    ok := func(mark rune) bool {
+        if mark == f.hardblank {
+            return false
+        }
+        for _, row := range glyph {
+            if strings.HasSuffix(row, string(mark)) {
+                return false
+            }
+        }
+        return true
+    }
+
+    for _, mark := range "@#$%&*" {
+        if ok(mark) {
+            return mark
+        }
+    }
+    // there are only so many rows, so one of these will do
+    for mark := '!'; ; mark++ {
+        if unicode.IsPrint(mark) && !unicode.IsSpace(mark) && ok(mark) {
+            return mark
+        }
+    }
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+    "bytes"
+    "reflect"
+    "testing"
+)
+
+// round_trip writes f and reads it back
+func round_trip(t *testing.T, f *FIGfont) *FIGfont {
+    var buf bytes.Buffer
+
+    if _, err := f.WriteTo(&buf); err != nil {
+        t.Fatal(err)
+    }
+    g, err := NewFIGfont(&buf)
+    if err != nil {
+        t.Fatal(err)
+    }
+    return g
+}
+
+func TestWriteToRoundTrip(t *testing.T) {
+    for _, name := range []string{"univers", "nancyj-improved"} {
+        f, err := LoadFont(name)
+        if err != nil {
+            t.Fatal(err)
+        }
+        g := round_trip(t, f)
+
+        if g.Height != f.Height || g.hardblank != f.hardblank || g.baseline != f.baseline ||
+            g.oldlayout != f.oldlayout || g.layout != f.layout || g.direction != f.direction {
+            t.Errorf("%s: header changed: %+v, want %+v", name,
+                []int{g.Height, int(g.hardblank), g.baseline, g.oldlayout, g.layout, g.direction},
+                []int{f.Height, int(f.hardblank), f.baseline, f.oldlayout, f.layout, f.direction})
+        }
+        if !reflect.DeepEqual(g.comment, f.comment) {
+            t.Errorf("%s: comments changed", name)
+        }
+        if !reflect.DeepEqual(g.chars, f.chars) {
+            t.Errorf("%s: FIGcharacters changed", name)
+        }
+        if !reflect.DeepEqual(g.Render("Hi 88.1"), f.Render("Hi 88.1")) {
+            t.Errorf("%s: renders differently", name)
+        }
+    }
+}
+
+func TestWriteToEndmark(t *testing.T) {
+    f, err := LoadFont("univers")
+    if err != nil {
+        t.Fatal(err)
+    }
+    // rows ending in every usual endmark
+    glyph := make([]string, f.Height)
+    for i := range glyph {
+        glyph[i] = "x" + string("@#$%&*!"[i % 7])
+    }
+    f.chars['A'] = glyph
+
+    g := round_trip(t, f)
+    if !reflect.DeepEqual(g.chars['A'], glyph) {
+        t.Errorf("got %q, want %q", g.chars['A'], glyph)
+    }
+}
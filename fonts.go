@@ -0,0 +1,343 @@
+package main
+
+import (
+    "embed"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path"
+    "sort"
+    "strings"
+
+    "github.com/mattn/go-runewidth"
+)
+
+// FIGfonts built into the binary
+//go:embed *.flf
+var embedded embed.FS
+
+// FontDir is searched for fonts that aren't built in
+var FontDir = "/usr/share/figlet"
+
+// extensions of font files, in the order they're searched
+var font_exts = []string{".flf", ".tlf"}
+
+var ErrNoFont = errors.New("no such font")
+
+// LoadFont loads a font by name, with or without its extension, from the
+// embedded fonts and then from FontDir
+func LoadFont(name string) (*FIGfont, error) {
+    var err error
+    var r io.ReadCloser
+    var f *FIGfont
+
+    if is_font_ext(path.Ext(name)) {
+        name = strings.TrimSuffix(name, path.Ext(name))
+    }
+    for _, fsys := range font_fs() {
+        for _, ext := range font_exts {
+            if r, err = fsys.Open(name + ext); err != nil {
+                continue
+            }
+            f, err = NewFIGfont(r)
+            r.Close()
+            if err != nil {
+                return nil, fmt.Errorf("%s%s: %w", name, ext, err)
+            }
+            f.Name = name
+            return f, nil
+        }
+    }
+    return nil, fmt.Errorf("%w: %s", ErrNoFont, name)
+}
+
+// Fonts returns the names of every font LoadFont can find
+func Fonts() []string {
+    var names []string
+
+    seen := map[string]bool{}
+    for _, fsys := range font_fs() {
+        entries, _ := fs.ReadDir(fsys, ".")
+        for _, e := range entries {
+            ext := path.Ext(e.Name())
+            name := strings.TrimSuffix(e.Name(), ext)
+            if e.IsDir() || !is_font_ext(ext) || seen[name] {
+                continue
+            }
+            seen[name] = true
+            names = append(names, name)
+        }
+    }
+    sort.Strings(names)
+    return names
+}
+
+// font_fs returns the places fonts are searched for, in order
+func font_fs() []fs.FS {
+    fsyss := []fs.FS{embedded}
+    if FontDir != "" {
+        fsyss = append(fsyss, os.DirFS(FontDir))
+    }
+    return fsyss
+}
+
+func is_font_ext(ext string) bool {
+    for _, e := range font_exts {
+        if ext == e {
+            return true
+        }
+    }
+    return false
+}
+
+// FontSet holds fonts ordered from tallest to shortest
+type FontSet struct {
+    fonts  []*FIGfont
+
+    // the last RenderFit, which is usually asked for again
+    last   fit
+}
+
+type fit struct {
+    ok         bool
+    text       string
+    width      int
+    maxHeight  int
+    out        []string
+    font       *FIGfont
+}
+
+func NewFontSet(fonts ...*FIGfont) *FontSet {
+    set := FontSet{fonts: append([]*FIGfont{}, fonts...)}
+    sort.SliceStable(set.fonts, func(i, j int) bool {
+        return set.fonts[i].Height > set.fonts[j].Height
+    })
+    return &set
+}
+
+// RenderFit renders text, wrapped to width, in the largest font whose output
+// is no more than maxHeight rows.  If no font fits the text is wrapped as
+// plain text and the returned font is nil.  The result is reused until text,
+// width or maxHeight changes, so it mustn't be modified.
+func (set *FontSet) RenderFit(text string, width, maxHeight int) ([]string, *FIGfont) {
+    if maxHeight <= 0 {
+        return nil, nil
+    }
+    if set.last.ok && set.last.text == text && set.last.width == width && set.last.maxHeight == maxHeight {
+        return set.last.out, set.last.font
+    }
+    out, f := set.render_fit(text, width, maxHeight)
+    set.last = fit{ok: true, text: text, width: width, maxHeight: maxHeight, out: out, font: f}
+    return out, f
+}
+
+func (set *FontSet) render_fit(text string, width, maxHeight int) ([]string, *FIGfont) {
+    var out []string
+
+    for _, f := range set.fonts {
+        if f.Height > maxHeight {
+            continue
+        }
+        out = f.RenderWith(text, RenderOptions{Width: width, Wrap: true, Trim: true})
+        if len(out) <= maxHeight && cells(out) <= width {
+            return out, f
+        }
+    }
+    return wrap_plain(text, width, maxHeight), nil
+}
+
+// cells returns the width of the widest line, in terminal cells
+func cells(lines []string) int {
+    var w int
+
+    for _, line := range lines {
+        if n := runewidth.StringWidth(line); n > w {
+            w = n
+        }
+    }
+    return w
+}
+
+// wrap_plain wraps text between words into at most maxHeight lines of width
+// cells, truncating anything that doesn't fit
+func wrap_plain(text string, width, maxHeight int) []string {
+    var lines []string
+    var line, try string
+
+    if maxHeight <= 0 {
+        return nil
+    }
+    for _, word := range strings.Fields(text) {
+        try = word
+        if line != "" {
+            try = line + " " + word
+        }
+        if line == "" || runewidth.StringWidth(try) <= width {
+            line = try
+            continue
+        }
+        lines = append(lines, runewidth.Truncate(line, width, ""))
+        line = word
+    }
+    if line != "" {
+        lines = append(lines, runewidth.Truncate(line, width, ""))
+    }
+    if len(lines) > maxHeight {
+        lines = lines[:maxHeight]
+    }
+    return lines
+}
+
+// FontChain renders each character in the first of its fonts that has a
+// FIGcharacter for it, aligning the fonts on their baselines
+type FontChain struct {
+    Fonts        []*FIGfont
+
+    // drawn, top-aligned, for characters none of the fonts have; empty for
+    // an empty box
+    Replacement  []string
+}
+
+// stands in for each font's hardblank when joining their output
+const chain_hardblank = '\uE000'
+
+func NewFontChain(fonts ...*FIGfont) *FontChain {
+    return &FontChain{Fonts: fonts}
+}
+
+// Height of the chain's output, which fits the tallest font, the lowest
+// descender of any font and the replacement glyph
+func (fc *FontChain) Height() int {
+    var baseline, descent int
+
+    for _, f := range fc.Fonts {
+        if f.baseline > baseline {
+            baseline = f.baseline
+        }
+        if f.Height - f.baseline > descent {
+            descent = f.Height - f.baseline
+        }
+    }
+    if n := len(fc.replacement(1, baseline)); n > baseline + descent {
+        return n
+    }
+    return baseline + descent
+}
+
+// font returns the first font with a FIGcharacter for c, or nil
+func (fc *FontChain) font(c rune) *FIGfont {
+    for _, f := range fc.Fonts {
+        if _, ok := f.glyphs[c]; ok {
+            return f
+        }
+    }
+    return nil
+}
+
+// Render s as a single line.  Runs of characters in the same font are
+// smushed according to that font's layout, and the runs are kerned together.
+func (fc *FontChain) Render(s string) []string {
+    var out, rows [][]rune
+    var run []rune
+    var runfont *FIGfont
+    var lines []string
+    var prevw int
+
+    height := fc.Height()
+    baseline := 0
+    for _, f := range fc.Fonts {
+        if f.baseline > baseline {
+            baseline = f.baseline
+        }
+    }
+    joiner := &FIGfont{Height: height, hardblank: chain_hardblank, layout: horizontal_fit}
+
+    flush := func() {
+        if len(run) == 0 {
+            return
+        }
+        if runfont != nil {
+            rows = runfont.render(string(run), false, 0).rows
+            for i := range rows {
+                for j := range rows[i] {
+                    if rows[i][j] == runfont.hardblank {
+                        rows[i][j] = chain_hardblank
+                    }
+                }
+            }
+            rows = pad_rows(rows, baseline - runfont.baseline, height)
+        } else {
+            rows = pad_rows(fc.replacement(len(run), baseline), 0, height)
+        }
+        // the runs are kerned together as if they were single FIGcharacters
+        curw := len(rows[0])
+        amt := joiner.smushamt(out, rows, prevw, curw, false)
+        out = joiner.addchar(out, rows, amt, prevw, curw, false)
+        prevw = curw
+        run = run[:0]
+    }
+
+    out = make([][]rune, height)
+    for _, c := range s {
+        f := fc.font(c)
+        if f != runfont {
+            flush()
+            runfont = f
+        }
+        run = append(run, c)
+    }
+    flush()
+
+    for _, row := range out {
+        lines = append(lines, strings.Replace(string(row), string(chain_hardblank), " ", -1))
+    }
+    return lines
+}
+
+// replacement returns n replacement glyphs side by side
+func (fc *FontChain) replacement(n, baseline int) [][]rune {
+    var glyph []string
+
+    glyph = fc.Replacement
+    if len(glyph) == 0 {
+        // a box from the top of the tallest font down to the baseline
+        if baseline < 2 {
+            baseline = 2
+        }
+        glyph = []string{"+--+"}
+        for i:=1; i<baseline-1; i++ {
+            glyph = append(glyph, "|  |")
+        }
+        glyph = append(glyph, "+--+")
+    }
+    rows := make([][]rune, len(glyph))
+    for i, line := range glyph {
+        rows[i] = []rune(strings.Repeat(line + " ", n))
+    }
+    return rows
+}
+
+// pad_rows moves rows down by top, makes them height rows tall and pads
+// them all to the same width
+func pad_rows(rows [][]rune, top, height int) [][]rune {
+    var w int
+
+    if top < 0 {
+        top = 0
+    }
+    for _, row := range rows {
+        if len(row) > w {
+            w = len(row)
+        }
+    }
+    out := make([][]rune, height)
+    for i := range out {
+        out[i] = []rune(strings.Repeat(" ", w))
+        if i >= top && i-top < len(rows) {
+            copy(out[i], rows[i-top])
+        }
+    }
+    return out
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "reflect"
+    "strings"
+    "testing"
+)
+
+func test_fontset(t *testing.T) *FontSet {
+    var fonts []*FIGfont
+
+    for _, name := range []string{"univers", "nancyj-improved"} {
+        f, err := LoadFont(name)
+        if err != nil {
+            t.Fatal(err)
+        }
+        fonts = append(fonts, f)
+    }
+    return NewFontSet(fonts...)
+}
+
+func TestLoadFontName(t *testing.T) {
+    b, err := embedded.ReadFile("univers.flf")
+    if err != nil {
+        t.Fatal(err)
+    }
+    dir := t.TempDir()
+    if err = os.WriteFile(filepath.Join(dir, "ansi.shadow.flf"), b, 0644); err != nil {
+        t.Fatal(err)
+    }
+    defer func(d string) { FontDir = d }(FontDir)
+    FontDir = dir
+
+    names := Fonts()
+    if !reflect.DeepEqual(names, []string{"ansi.shadow", "nancyj-improved", "univers"}) {
+        t.Errorf("Fonts() = %q", names)
+    }
+    // everything Fonts lists loads back under the same name
+    for _, name := range append(names, "ansi.shadow.flf", "univers.flf") {
+        f, err := LoadFont(name)
+        if err != nil {
+            t.Errorf("LoadFont(%q): %v", name, err)
+            continue
+        }
+        if want := strings.TrimSuffix(name, ".flf"); f.Name != want {
+            t.Errorf("LoadFont(%q) is named %q, want %q", name, f.Name, want)
+        }
+    }
+}
+
+func TestRenderFitNoRoom(t *testing.T) {
+    set := test_fontset(t)
+    for _, h := range []int{-3, 0} {
+        if out, f := set.RenderFit("hello", 80, h); out != nil || f != nil {
+            t.Errorf("RenderFit(maxHeight %d) = %q, %v, want nothing", h, out, f)
+        }
+        if out := wrap_plain("hello", 80, h); out != nil {
+            t.Errorf("wrap_plain(maxHeight %d) = %q, want nothing", h, out)
+        }
+    }
+}
+
+func TestRenderFit(t *testing.T) {
+    tests := []struct {
+        text       string
+        width      int
+        maxHeight  int
+        font       string  // "" for plain text
+        lines      int
+    }{
+        {"hi", 80, 20, "univers", 11},
+        {"hi", 80, 10, "nancyj-improved", 8},
+        {"hi", 80, 5, "", 1},
+        {"hello world", 50, 20, "nancyj-improved", 16},
+    }
+    for _, tt := range tests {
+        out, f := test_fontset(t).RenderFit(tt.text, tt.width, tt.maxHeight)
+        name := ""
+        if f != nil {
+            name = f.Name
+        }
+        if name != tt.font || len(out) != tt.lines {
+            t.Errorf("RenderFit(%q, %d, %d): %d lines in %q, want %d in %q",
+                tt.text, tt.width, tt.maxHeight, len(out), name, tt.lines, tt.font)
+        }
+        if cells(out) > tt.width {
+            t.Errorf("RenderFit(%q, %d, %d): %d cells wide", tt.text, tt.width, tt.maxHeight, cells(out))
+        }
+    }
+}
+
+func TestRenderFitCache(t *testing.T) {
+    set := test_fontset(t)
+    a, _ := set.RenderFit("hello", 80, 20)
+    b, _ := set.RenderFit("hello", 80, 20)
+    if &a[0] != &b[0] {
+        t.Error("same arguments rendered again")
+    }
+    allocs := testing.AllocsPerRun(10, func() {
+        set.RenderFit("hello", 80, 20)
+    })
+    if allocs != 0 {
+        t.Errorf("cached RenderFit allocates %v times", allocs)
+    }
+    for _, args := range []struct{ text string; width, maxHeight int }{
+        {"hello!", 80, 20},
+        {"hello!", 40, 20},
+        {"hello!", 40, 9},
+    } {
+        c, _ := set.RenderFit(args.text, args.width, args.maxHeight)
+        if &c[0] == &a[0] {
+            t.Errorf("RenderFit%v reused the last result", args)
+        }
+        a = c
+    }
+}
+
+func TestFontChainKerning(t *testing.T) {
+    f, err := LoadFont("univers")
+    if err != nil {
+        t.Fatal(err)
+    }
+    // the replacement box is kerned up to the univers runs on both sides,
+    // stopping at univers' hardblanks
+    want := []string{
+        "              +--+              ",
+        "88        88  |  |88        88  ",
+        "88        88  |  |88        88  ",
+        "88        88  |  |88        88  ",
+        "88aaaaaaaa88  |  |88aaaaaaaa88  ",
+        "88\"\"\"\"\"\"\"\"88  |  |88\"\"\"\"\"\"\"\"88  ",
+        "88        88  |  |88        88  ",
+        "88        88  |  |88        88  ",
+        "88        88  +--+88        88  ",
+        "                                ",
+        "                                ",
+    }
+    got := NewFontChain(f).Render("H☃H")
+    if !reflect.DeepEqual(got, want) {
+        t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+    }
+}
+
+func TestFontChainMissing(t *testing.T) {
+    // one row tall, with its baseline at the top
+    b := "flf2a$ 1 0 10 0 0\n" + strings.Repeat("x@\n", 95 + 7)
+    short, err := NewFIGfont(strings.NewReader(b))
+    if err != nil {
+        t.Fatal(err)
+    }
+    box := []string{
+        "+--+ +--+ ",
+        "+--+ +--+ ",
+    }
+    tests := []struct {
+        name   string
+        fc     *FontChain
+        s      string
+        want   []string
+    }{
+        {"empty chain", NewFontChain(), "☃☃", box},
+        {"empty chain, no text", NewFontChain(), "", []string{"", ""}},
+        {"all missing", NewFontChain(short), "☃☃", box},
+        {"empty replacement", &FontChain{Fonts: []*FIGfont{short}, Replacement: []string{}}, "☃☃", box},
+        {"tall replacement", &FontChain{Replacement: []string{"#", "#", "#"}}, "☃", []string{"# ", "# ", "# "}},
+    }
+    for _, tt := range tests {
+        if got := tt.fc.Render(tt.s); !reflect.DeepEqual(got, tt.want) {
+            t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+        }
+    }
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+    "flag"
+    "fmt"
+    "os"
+    "strings"
+    "text/tabwriter"
+)
+
+// fonts_main lists the available fonts for "gofm fonts", optionally
+// rendering sample text in each
+func fonts_main(args []string) int {
+    var f *FIGfont
+    var err error
+
+    flags := flag.NewFlagSet("fonts", flag.ContinueOnError)
+    preview := flags.String("preview", "", "render `TEXT` in each font")
+    flags.StringVar(&FontDir, "dir", FontDir, "font `directory` searched after the built-in fonts")
+    if err = flags.Parse(args); err != nil {
+        return 2
+    }
+
+    tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+    if *preview == "" {
+        fmt.Fprintln(tw, "NAME\tHEIGHT\tBASELINE\tLAYOUT\tGLYPHS")
+    }
+    for _, name := range Fonts() {
+        if f, err = LoadFont(name); err != nil {
+            fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+            continue
+        }
+        if *preview == "" {
+            fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%d\n", f.Name, f.Height, f.Baseline(), f.LayoutMode(), f.NumGlyphs())
+            continue
+        }
+        // FIGcharacters may contain tabs, so previews bypass the tabwriter
+        fmt.Printf("%s (height %d, baseline %d, %s, %d glyphs)\n", f.Name, f.Height, f.Baseline(), f.LayoutMode(), f.NumGlyphs())
+        fmt.Println(strings.Join(f.RenderWith(*preview, RenderOptions{Trim: true}), "\n"))
+    }
+    tw.Flush()
+    return 0
+}
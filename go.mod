@@ -4,6 +4,7 @@ go 1.19
 
 require (
 	github.com/gdamore/tcell v1.4.0
+	github.com/mattn/go-runewidth v0.0.7
 	periph.io/x/conn/v3 v3.7.0
 	periph.io/x/host/v3 v3.8.0
 )
@@ -11,7 +12,6 @@ require (
 require (
 	github.com/gdamore/encoding v1.0.0 // indirect
 	github.com/lucasb-eyer/go-colorful v1.0.3 // indirect
-	github.com/mattn/go-runewidth v0.0.7 // indirect
 	golang.org/x/sys v0.0.0-20190626150813-e07cf5db2756 // indirect
 	golang.org/x/text v0.3.0 // indirect
 )
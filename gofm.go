@@ -2,8 +2,9 @@ package main
 
 import (
     "fmt"
-    "io"
+    "image/color"
     "os"
+    "strings"
     "time"
 
     "github.com/gdamore/tcell"
@@ -22,10 +23,13 @@ var i2c_addr = 0x10
 
 func main() {
     var err error
-    var r io.Reader
     var scr tcell.Screen
     var big, medium *FIGfont
 
+    if len(os.Args) > 1 && os.Args[1] == "fonts" {
+        os.Exit(fonts_main(os.Args[2:]))
+    }
+
     if scr, err = tcell.NewScreen(); err != nil {
         fmt.Println("couldn't open screen:", err)
         return
@@ -36,19 +40,11 @@ func main() {
     defer scr.Fini()
     scr.Clear()
 
-    if r, err = os.Open("univers.flf"); err != nil {
-        fmt.Println(err)
-        return
-    }
-    if big, err = NewFIGfont(r); err != nil {
+    if big, err = LoadFont("univers"); err != nil {
         fmt.Println(err)
         return
     }
-    if r, err = os.Open("nancyj-improved.flf"); err != nil {
-        fmt.Println(err)
-        return
-    }
-    if medium, err = NewFIGfont(r); err != nil {
+    if medium, err = LoadFont("nancyj-improved"); err != nil {
         fmt.Println(err)
         return
     }
@@ -145,11 +141,14 @@ func main() {
 //    style = style.Foreground(fg)
 //    style = style.Background(bg)
 
+    d := new_display(big, medium, freq_style, call_style)
+
     // print rssi, checksum X, ....
-    var rssi, x_tmp int
+    var rssi int
     var rdsr, traffic rune
     var msg, stereo string
     rds := RDS{}
+    rds.RegisterODA(RTPlusAID, NewRTPlus)
 
     scr.Clear()
     scr.EnableMouse()
@@ -160,7 +159,6 @@ func main() {
         }
     }()
 
-    w, _ := scr.Size()
     var e tcell.Event
     evtloop:
     for {
@@ -176,14 +174,18 @@ func main() {
                                     channel = 87.5
                                 }
                                 s.SetChannel(channel)
-                                rds = RDS{}
+                                rds.Reset()
                             case tcell.KeyDown:
                                 channel -= .2
                                 if channel < 87.5 {
                                     channel = 107.9
                                 }
                                 s.SetChannel(channel)
-                                rds = RDS{}
+                                rds.Reset()
+                            case tcell.KeyRune:
+                                if e.Rune() == 's' {
+                                    screenshot(scr, call_style)
+                                }
                         }
                 }
             case <-s.Update:
@@ -210,36 +212,120 @@ func main() {
 
                 rssi = int(s.Reg[STATUSRSSI] & 0xff)
                 actual := float64(87.5 + (.2 * float64(s.Reg[READCHAN] & 0x1ff)))
-                msg = fmt.Sprintf("%.1f (%.1f)  %.4s (%s) : %3.d  %s  %c  %c  : %.8s : %s\n", channel, actual, rds.CallSign[:], PT_NA[rds.ProgramType], rssi, stereo, rdsr, traffic, rds.ProgramService, rds.Radiotext)
+                msg = fmt.Sprintf("%.1f (%.1f)  %.4s (%s) : %3.d  %s  %c  %c  : %.8s : %s\n", channel, actual, rds.CallSign[:], pty_name(&rds), rssi, stereo, rdsr, traffic, rds.ProgramService, rds.Radiotext)
                 _ = msg
-                FREQ := big.Render(fmt.Sprintf("%.1f", channel))
-                CALL := medium.Render(string(rds.CallSign[:]))
-                PROG := medium.Render(rds.Radiotext)
+                d.draw(scr, channel, &rds)
+                scr.Show()
+        }
+    }
+}
 
-                x_tmp = (w - 60) / 2
-                Clear(scr, x_tmp, 4, big.Height+1, 60, ' ', freq_style)
-                x_tmp = (w - len(FREQ[0])) / 2
-                DrawLines(scr, x_tmp, 2, freq_style, FREQ)
+// call_sign returns the station's call sign, or "" until one's been received
+func call_sign(r *RDS) string {
+    if r.CallSign[0] == 0 {
+        return ""
+    }
+    return string(r.CallSign[:])
+}
 
-                x_tmp = (w - 50) / 2
-                Clear(scr, x_tmp, 18, medium.Height, 50, ' ', call_style)
-                x_tmp = (w - len(CALL[0])) / 2
-                DrawLines(scr, x_tmp, 15, call_style, CALL)
+// display is what's drawn on the screen, kept between updates
+type display struct {
+    big, medium  *FIGfont
+    text         *FontSet
+    calls        *FontChain
+    freq_style   tcell.Style
+    call_style   tcell.Style
+
+    freq_buf     [][]rune
+    CALL         []string
+    call         string
+}
 
-                x_tmp = (w - len(PT_NA[rds.ProgramType])) / 2
-                DrawLines(scr, x_tmp, 22, call_style, []string{PT_NA[rds.ProgramType]})
+func new_display(big, medium *FIGfont, freq_style, call_style tcell.Style) *display {
+    return &display{
+        big: big,
+        medium: medium,
+        text: NewFontSet(big, medium),
+        // call signs are drawn with a box for anything the font lacks,
+        // rather than dropping it
+        calls: NewFontChain(medium),
+        freq_style: freq_style,
+        call_style: call_style,
+    }
+}
 
-                Clear(scr, 0, 24, medium.Height, w, ' ', call_style)
-                DrawLines(scr, 0, 24, call_style, PROG)
-                scr.Show()
+// draw the tuned channel and what the station's sending
+func (d *display) draw(scr tcell.Screen, channel float64, rds *RDS) {
+    var x_tmp int
+
+    w, h := scr.Size()
+    pty := pty_name(rds)
+    // rendered every update, so reuse the buffer
+    d.freq_buf = d.big.RenderInto(d.freq_buf, fmt.Sprintf("%.1f", channel), RenderOptions{Justify: JustifyCenter, Width: 60})
+    if cs := call_sign(rds); d.CALL == nil || cs != d.call {
+        // it rarely changes, so only render it when it does
+        d.call = cs
+        d.CALL = d.calls.Render(d.call)
+    }
+    // leave room for the plain radiotext and program service
+    prog := rds.Radiotext
+    if prog == "" {
+        // nothing confirmed yet, show what's arrived
+        prog = rds.RadiotextPartial
+    }
+    if np, ok := rds.NowPlaying(); ok && np.ItemRunning && np.Title != "" {
+        prog = np.Title
+        if np.Artist != "" {
+            prog = np.Artist +" - "+ np.Title
+        }
+    }
+    var PROG []string
+    if h-24-3 > 0 {
+        // short terminals have no room for it
+        PROG, _ = d.text.RenderFit(prog, w, h-24-3)
+    }
 
-                rt := "- - - = = =  "+ rds.Radiotext +"  = = = - - -"
-                rt_x := (w - len(rt)) / 2
-                Clear(scr, 0, 33, 1, w, ' ', call_style)
-                DrawLines(scr, rt_x, 33, call_style, []string{rt})
+    x_tmp = (w - 60) / 2
+    Clear(scr, x_tmp, 4, d.big.Height+1, 60, ' ', d.freq_style)
+    DrawRunes(scr, x_tmp, 2, d.freq_style, d.freq_buf)
 
-                x_tmp := (w - len(rds.ProgramService)) / 2
-                DrawLines(scr, x_tmp, 34, call_style, []string{"("+ rds.ProgramService +")"})
-        }
+    x_tmp = (w - 50) / 2
+    Clear(scr, x_tmp, 15, d.calls.Height(), 50, ' ', d.call_style)
+    DrawLines(scr, (w - cells(d.CALL)) / 2, 15, d.call_style, d.CALL)
+
+    x_tmp = (w - len(pty)) / 2
+    Clear(scr, 0, 22, 1, w, ' ', d.call_style)
+    DrawLines(scr, x_tmp, 22, d.call_style, []string{pty})
+
+    // radiotext wraps, so clear everything below it
+    Clear(scr, 0, 24, h-24, w, ' ', d.call_style)
+    DrawLines(scr, 0, 24, d.call_style, PROG)
+    y_tmp := 24 + len(PROG) + 1
+
+    rt := "- - - = = =  "+ rds.RadiotextPartial +"  = = = - - -"
+    rt_x := (w - len(rt)) / 2
+    DrawLines(scr, rt_x, y_tmp, d.call_style, []string{rt})
+
+    x_tmp = (w - len(rds.ProgramService)) / 2
+    DrawLines(scr, x_tmp, y_tmp+1, d.call_style, []string{"("+ rds.ProgramService +")"})
+}
+
+// pty_name returns the station's own name for its program type, if it sends
+// one, or the standard name
+func pty_name(r *RDS) string {
+    if ptyn := strings.TrimSpace(r.ProgramTypeName); ptyn != "" {
+        return ptyn
+    }
+    return PT_NA[r.ProgramType]
+}
+
+// screenshot saves the screen to screenshot.png, as shown in the README
+func screenshot(scr tcell.Screen, style tcell.Style) {
+    img := RenderImage(ScreenCells(scr), color.White, color.Black, 2)
+    if err := WritePNG("screenshot.png", img); err != nil {
+        w, h := scr.Size()
+        Clear(scr, 0, h-1, 1, w, ' ', style)
+        DrawLines(scr, 0, h-1, style, []string{err.Error()})
+        scr.Show()
     }
 }
@@ -0,0 +1,275 @@
+package main
+
+import (
+    "image"
+    "image/color"
+    "image/draw"
+    "image/png"
+    "os"
+)
+
+// size of a character cell in pixels, before scaling: a 5x7 glyph with a
+// column of spacing on the right and a row below
+const (
+    cell_width  = 6
+    cell_height = 8
+)
+
+// Lines converts plain text lines, such as FIGfont.Render output, to cells
+// in the default colors
+func Lines(lines []string) [][]Cell {
+    var cells [][]Cell
+
+    for _, line := range lines {
+        row := []Cell{}
+        for _, c := range line {
+            row = append(row, Cell{Rune: c})
+        }
+        cells = append(cells, row)
+    }
+    return cells
+}
+
+// RenderImage draws cells with the built-in bitmap font, each pixel scale
+// pixels square.  fg and bg are used where a cell has no color of its own.
+func RenderImage(cells [][]Cell, fg, bg color.Color, scale int) *image.RGBA {
+    var w, x, y, cw int
+
+    if scale < 1 {
+        scale = 1
+    }
+    for _, row := range cells {
+        cw = 0
+        for _, c := range row {
+            cw += cell_cols(c.Rune)
+        }
+        if cw > w {
+            w = cw
+        }
+    }
+
+    img := image.NewRGBA(image.Rect(0, 0, w*cell_width*scale, len(cells)*cell_height*scale))
+    draw.Draw(img, img.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+    for y = range cells {
+        x = 0
+        for _, c := range cells[y] {
+            cfg, cbg := fg, bg
+            if c.Fg != nil {
+                cfg = c.Fg
+            }
+            if c.Bg != nil {
+                cbg = c.Bg
+            }
+            cw = cell_cols(c.Rune)
+            r := image.Rect(x*cell_width*scale, y*cell_height*scale, (x+cw)*cell_width*scale, (y+1)*cell_height*scale)
+            draw.Draw(img, r, image.NewUniform(cbg), image.Point{}, draw.Src)
+            draw_rune(img, r, c.Rune, cfg, scale)
+            x += cw
+        }
+    }
+    return img
+}
+
+// WritePNG saves img to the file name as a PNG
+func WritePNG(name string, img image.Image) error {
+    f, err := os.Create(name)
+    if err != nil {
+        return err
+    }
+    if err = png.Encode(f, img); err != nil {
+        f.Close()
+        return err
+    }
+    return f.Close()
+}
+
+// cell_cols is how many cells wide c is, at least one so nothing vanishes
+func cell_cols(c rune) int {
+    for _, r := range wide_runes {
+        if c >= r[0] && c <= r[1] {
+            return 2
+        }
+    }
+    return 1
+}
+
+// the East Asian wide and fullwidth ranges, which take two cells
+var wide_runes = [][2]rune{
+    {0x1100, 0x115f},    // Hangul Jamo initial consonants
+    {0x2e80, 0x303e},    // CJK radicals through CJK symbols and punctuation
+    {0x3041, 0x33ff},    // Hiragana through CJK compatibility
+    {0x3400, 0x4dbf},    // CJK unified ideographs extension A
+    {0x4e00, 0x9fff},    // CJK unified ideographs
+    {0xa000, 0xa4cf},    // Yi
+    {0xac00, 0xd7a3},    // Hangul syllables
+    {0xf900, 0xfaff},    // CJK compatibility ideographs
+    {0xfe30, 0xfe4f},    // CJK compatibility forms
+    {0xff00, 0xff60},    // fullwidth forms
+    {0xffe0, 0xffe6},    // fullwidth signs
+    {0x1f300, 0x1f64f},  // pictographs and emoticons
+    {0x1f900, 0x1f9ff},  // supplemental symbols and pictographs
+    {0x20000, 0x2fffd},  // CJK extensions B and later
+    {0x30000, 0x3fffd},
+}
+
+// draw_rune draws c into the cell r
+func draw_rune(img *image.RGBA, r image.Rectangle, c rune, fg color.Color, scale int) {
+    var px, py int
+
+    dot := func(x, y int) {
+        for j:=0; j<scale; j++ {
+            for i:=0; i<scale; i++ {
+                img.Set(r.Min.X + x*scale + i, r.Min.Y + y*scale + j, fg)
+            }
+        }
+    }
+    cols, rows := r.Dx()/scale, r.Dy()/scale
+
+    switch {
+        case c == ' ':
+        case c >= 0x20 && c < 0x7f:
+            glyph := font5x7[c-0x20]
+            for py=0; py<7; py++ {
+                for px=0; px<5; px++ {
+                    if glyph[py] & (0x10 >> px) != 0 {
+                        dot(px, py)
+                    }
+                }
+            }
+        case c == '█' || c == '▀' || c == '▄' || c == '▌' || c == '▐':
+            // full, upper half, lower half, left half and right half blocks
+            for py=0; py<rows; py++ {
+                for px=0; px<cols; px++ {
+                    if (c == '▀' && py >= rows/2) || (c == '▄' && py < rows/2) ||
+                       (c == '▌' && px >= cols/2) || (c == '▐' && px < cols/2) {
+                        continue
+                    }
+                    dot(px, py)
+                }
+            }
+        case c >= '░' && c <= '▓':
+            // light, medium and dark shades
+            for py=0; py<rows; py++ {
+                for px=0; px<cols; px++ {
+                    on := false
+                    switch c {
+                        case '░': on = px%2 == 0 && py%2 == 0
+                        case '▒': on = (px+py)%2 == 0
+                        case '▓': on = px%2 == 1 || py%2 == 1
+                    }
+                    if on {
+                        dot(px, py)
+                    }
+                }
+            }
+        default:
+            // anything else is an empty box
+            for py=0; py<7; py++ {
+                for px=0; px<cols-1; px++ {
+                    if py == 0 || py == 6 || px == 0 || px == cols-2 {
+                        dot(px, py)
+                    }
+                }
+            }
+    }
+}
+
+// 5x7 bitmaps for ' ' through '~', one byte per row with the leftmost
+// pixel in bit 4
+var font5x7 = [95][7]byte{
+    {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},  // space
+    {0x04, 0x04, 0x04, 0x04, 0x04, 0x00, 0x04},  // !
+    {0x0a, 0x0a, 0x0a, 0x00, 0x00, 0x00, 0x00},  // "
+    {0x0a, 0x0a, 0x1f, 0x0a, 0x1f, 0x0a, 0x0a},  // #
+    {0x04, 0x0f, 0x14, 0x0e, 0x05, 0x1e, 0x04},  // $
+    {0x18, 0x19, 0x02, 0x04, 0x08, 0x13, 0x03},  // %
+    {0x0c, 0x12, 0x14, 0x08, 0x15, 0x12, 0x0d},  // &
+    {0x04, 0x04, 0x08, 0x00, 0x00, 0x00, 0x00},  // '
+    {0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02},  // (
+    {0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08},  // )
+    {0x00, 0x04, 0x15, 0x0e, 0x15, 0x04, 0x00},  // *
+    {0x00, 0x04, 0x04, 0x1f, 0x04, 0x04, 0x00},  // +
+    {0x00, 0x00, 0x00, 0x00, 0x0c, 0x04, 0x08},  // ,
+    {0x00, 0x00, 0x00, 0x1f, 0x00, 0x00, 0x00},  // -
+    {0x00, 0x00, 0x00, 0x00, 0x00, 0x0c, 0x0c},  // .
+    {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},  // /
+    {0x0e, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0e},  // 0
+    {0x04, 0x0c, 0x04, 0x04, 0x04, 0x04, 0x0e},  // 1
+    {0x0e, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1f},  // 2
+    {0x1f, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0e},  // 3
+    {0x02, 0x06, 0x0a, 0x12, 0x1f, 0x02, 0x02},  // 4
+    {0x1f, 0x10, 0x1e, 0x01, 0x01, 0x11, 0x0e},  // 5
+    {0x06, 0x08, 0x10, 0x1e, 0x11, 0x11, 0x0e},  // 6
+    {0x1f, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},  // 7
+    {0x0e, 0x11, 0x11, 0x0e, 0x11, 0x11, 0x0e},  // 8
+    {0x0e, 0x11, 0x11, 0x0f, 0x01, 0x02, 0x0c},  // 9
+    {0x00, 0x0c, 0x0c, 0x00, 0x0c, 0x0c, 0x00},  // :
+    {0x00, 0x0c, 0x0c, 0x00, 0x0c, 0x04, 0x08},  // ;
+    {0x02, 0x04, 0x08, 0x10, 0x08, 0x04, 0x02},  // <
+    {0x00, 0x00, 0x1f, 0x00, 0x1f, 0x00, 0x00},  // =
+    {0x08, 0x04, 0x02, 0x01, 0x02, 0x04, 0x08},  // >
+    {0x0e, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04},  // ?
+    {0x0e, 0x11, 0x01, 0x0d, 0x15, 0x15, 0x0e},  // @
+    {0x0e, 0x11, 0x11, 0x1f, 0x11, 0x11, 0x11},  // A
+    {0x1e, 0x11, 0x11, 0x1e, 0x11, 0x11, 0x1e},  // B
+    {0x0e, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0e},  // C
+    {0x1c, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1c},  // D
+    {0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x1f},  // E
+    {0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x10},  // F
+    {0x0e, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0f},  // G
+    {0x11, 0x11, 0x11, 0x1f, 0x11, 0x11, 0x11},  // H
+    {0x0e, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0e},  // I
+    {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0c},  // J
+    {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},  // K
+    {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1f},  // L
+    {0x11, 0x1b, 0x15, 0x15, 0x11, 0x11, 0x11},  // M
+    {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},  // N
+    {0x0e, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e},  // O
+    {0x1e, 0x11, 0x11, 0x1e, 0x10, 0x10, 0x10},  // P
+    {0x0e, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0d},  // Q
+    {0x1e, 0x11, 0x11, 0x1e, 0x14, 0x12, 0x11},  // R
+    {0x0f, 0x10, 0x10, 0x0e, 0x01, 0x01, 0x1e},  // S
+    {0x1f, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},  // T
+    {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e},  // U
+    {0x11, 0x11, 0x11, 0x11, 0x11, 0x0a, 0x04},  // V
+    {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0a},  // W
+    {0x11, 0x11, 0x0a, 0x04, 0x0a, 0x11, 0x11},  // X
+    {0x11, 0x11, 0x0a, 0x04, 0x04, 0x04, 0x04},  // Y
+    {0x1f, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1f},  // Z
+    {0x0e, 0x08, 0x08, 0x08, 0x08, 0x08, 0x0e},  // [
+    {0x00, 0x10, 0x08, 0x04, 0x02, 0x01, 0x00},  // \
+    {0x0e, 0x02, 0x02, 0x02, 0x02, 0x02, 0x0e},  // ]
+    {0x04, 0x0a, 0x11, 0x00, 0x00, 0x00, 0x00},  // ^
+    {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1f},  // _
+    {0x08, 0x04, 0x02, 0x00, 0x00, 0x00, 0x00},  // `
+    {0x00, 0x00, 0x0e, 0x01, 0x0f, 0x11, 0x0f},  // a
+    {0x10, 0x10, 0x16, 0x19, 0x11, 0x11, 0x1e},  // b
+    {0x00, 0x00, 0x0e, 0x10, 0x10, 0x11, 0x0e},  // c
+    {0x01, 0x01, 0x0d, 0x13, 0x11, 0x11, 0x0f},  // d
+    {0x00, 0x00, 0x0e, 0x11, 0x1f, 0x10, 0x0e},  // e
+    {0x06, 0x09, 0x08, 0x1c, 0x08, 0x08, 0x08},  // f
+    {0x00, 0x0f, 0x11, 0x11, 0x0f, 0x01, 0x0e},  // g
+    {0x10, 0x10, 0x16, 0x19, 0x11, 0x11, 0x11},  // h
+    {0x04, 0x00, 0x0c, 0x04, 0x04, 0x04, 0x0e},  // i
+    {0x02, 0x00, 0x06, 0x02, 0x02, 0x12, 0x0c},  // j
+    {0x10, 0x10, 0x12, 0x14, 0x18, 0x14, 0x12},  // k
+    {0x0c, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0e},  // l
+    {0x00, 0x00, 0x1a, 0x15, 0x15, 0x11, 0x11},  // m
+    {0x00, 0x00, 0x16, 0x19, 0x11, 0x11, 0x11},  // n
+    {0x00, 0x00, 0x0e, 0x11, 0x11, 0x11, 0x0e},  // o
+    {0x00, 0x00, 0x1e, 0x11, 0x1e, 0x10, 0x10},  // p
+    {0x00, 0x00, 0x0d, 0x13, 0x0f, 0x01, 0x01},  // q
+    {0x00, 0x00, 0x16, 0x19, 0x10, 0x10, 0x10},  // r
+    {0x00, 0x00, 0x0e, 0x10, 0x0e, 0x01, 0x1e},  // s
+    {0x08, 0x08, 0x1c, 0x08, 0x08, 0x09, 0x06},  // t
+    {0x00, 0x00, 0x11, 0x11, 0x11, 0x13, 0x0d},  // u
+    {0x00, 0x00, 0x11, 0x11, 0x11, 0x0a, 0x04},  // v
+    {0x00, 0x00, 0x11, 0x11, 0x15, 0x15, 0x0a},  // w
+    {0x00, 0x00, 0x11, 0x0a, 0x04, 0x0a, 0x11},  // x
+    {0x00, 0x00, 0x11, 0x11, 0x0f, 0x01, 0x0e},  // y
+    {0x00, 0x00, 0x1f, 0x02, 0x04, 0x08, 0x1f},  // z
+    {0x02, 0x04, 0x04, 0x08, 0x04, 0x04, 0x02},  // {
+    {0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},  // |
+    {0x08, 0x04, 0x04, 0x02, 0x04, 0x04, 0x08},  // }
+    {0x00, 0x00, 0x08, 0x15, 0x02, 0x00, 0x00},  // ~
+}
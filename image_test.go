@@ -0,0 +1,101 @@
+package main
+
+import (
+    "flag"
+    "image"
+    "image/color"
+    "image/png"
+    "os"
+    "path/filepath"
+    "testing"
+
+    "github.com/gdamore/tcell"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images")
+
+func TestCellCols(t *testing.T) {
+    tests := []struct {
+        c     rune
+        cols  int
+    }{
+        {'a', 1},
+        {'█', 1},
+        {'é', 1},
+        {'☃', 1},
+        {'中', 2},
+        {'한', 2},
+        {'Ａ', 2},   // fullwidth
+        {'😀', 2},
+        {0, 1},
+    }
+    for _, tt := range tests {
+        if got := cell_cols(tt.c); got != tt.cols {
+            t.Errorf("cell_cols(%q) = %d, want %d", tt.c, got, tt.cols)
+        }
+    }
+}
+
+func TestDisplayImage(t *testing.T) {
+    big, err := LoadFont("univers")
+    if err != nil {
+        t.Fatal(err)
+    }
+    medium, err := LoadFont("nancyj-improved")
+    if err != nil {
+        t.Fatal(err)
+    }
+    freq_style := tcell.StyleDefault.Foreground(tcell.Color(int32(255))).Background(tcell.Color(int32(232))).Bold(true)
+    d := new_display(big, medium, freq_style, tcell.StyleDefault)
+
+    scr := tcell.NewSimulationScreen("")
+    if err = scr.Init(); err != nil {
+        t.Fatal(err)
+    }
+    defer scr.Fini()
+    scr.SetSize(80, 40)
+
+    rds := RDS{
+        CallSign: [4]byte{'K', 'Q', 'E', 'D'},
+        ProgramService: "KQED FM ",
+        ProgramType: 3,
+        Radiotext: "Forum with Mina Kim",
+        RadiotextPartial: "Forum with Mina Kim",
+    }
+    d.draw(scr, 88.5, &rds)
+    scr.Show()
+    img := RenderImage(ScreenCells(scr), color.White, color.Black, 1)
+
+    golden_image(t, filepath.Join("testdata", "display.png"), img)
+}
+
+// golden_image compares img to the PNG file, or rewrites the file with -update
+func golden_image(t *testing.T, file string, img *image.RGBA) {
+    if *update {
+        if err := WritePNG(file, img); err != nil {
+            t.Fatal(err)
+        }
+        return
+    }
+    f, err := os.Open(file)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer f.Close()
+    want, err := png.Decode(f)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if want.Bounds() != img.Bounds() {
+        t.Fatalf("%s: image is %v, want %v", file, img.Bounds(), want.Bounds())
+    }
+    b := img.Bounds()
+    for y := b.Min.Y; y < b.Max.Y; y++ {
+        for x := b.Min.X; x < b.Max.X; x++ {
+            if color.RGBAModel.Convert(want.At(x, y)) != img.At(x, y) {
+                t.Fatalf("%s: pixel (%d, %d) is %v, want %v; rerun with -update if the layout changed on purpose",
+                    file, x, y, img.At(x, y), want.At(x, y))
+            }
+        }
+    }
+}
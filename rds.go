@@ -1,5 +1,13 @@
 package main
 
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+    "unicode/utf8"
+)
+
 /*
 
 * rdsa: 16 bit PI Code; NA: encoded call sign, EU: country/coverage/program reference
@@ -24,6 +32,9 @@ RDS group types sometimes:
     Decoder identification (DI) code (0A, 0B, 15B)
     Music/speech (M/S) code (0A, 0B, 15B)
     Radiotext (RT) message (2A, 2B)
+    Program type name (PTYN) (10A)
+    Long PS name (15A, RDS2)
+    Clock time and date (CT) (4A)
     Enhanced other networks information (EON) (14A)
 
 (the cast majority of observed RDS group types is 0A/0B and 2A/2B)
@@ -70,12 +81,35 @@ type RDS struct {
 
     // variable
     ProgramService      string  // PS - 64 chars, song title, artist, etc.
+    ProgramTypeName     string  // PTYN - 8 chars, refines PTY, ex. "Football"
+    LongProgramService  string  // Long PS - up to 32 bytes of UTF-8
     AltFreqs            map[float64]bool  // AF
     NumAltFreqs         int
+    OtherNetworks       map[uint16]*OtherNetwork  // EON - by the other network's PI
+    TrafficEvents       []TrafficEvent            // EON - traffic announcements, oldest first
     Radiotext           string  // RT
-
-    // application identification codes for open data applications
-    aid    map[uint16][32]uint16
+    RadiotextPartial    string  // RT - as received so far, unconfirmed
+
+    // clock time, sent once a minute
+    ClockTime           time.Time      // CT - in the station's local time zone
+    LocalOffset         time.Duration  // station's offset from UTC
+    ClockTimeValid      bool           // false if the last CT was implausible
+    ClockTimeReceived   time.Time      // when the last CT arrived
+
+    // program item number and slow labeling codes (1A, 1B)
+    ProgramItem           PIN     // PIN - scheduled start of the program
+    LinkageActuator       bool    // LA - the linkage set is in use
+    ExtendedCountryCode   uint8   // ECC - with the PI country, identifies the country
+    Paging                uint8   // operator code for radio paging
+    TMCIdentification     uint16  // TMC - service identifier, see group 8A
+    PagingIdentification  uint16  // identifies the radio paging service
+    LanguageCode          uint8   // language of the program
+    EWSIdentification     uint16  // EWS - emergency warning channel identifier
+
+    // open data applications announced in 3A groups, by PI then AID
+    odas      map[uint16]map[uint16]*ODA
+    // constructors of registered ODA decoders, by AID
+    decoders  map[uint16]func() ODADecoder
 
     // call sign triple buffering
     cs1    [4]byte
@@ -86,10 +120,79 @@ type RDS struct {
     ps2    [8]byte
     psnew  [8]bool
 
+    // long program service triple buffering
+    lps1   [32]byte
+    lps2   [32]byte
+
+    // program type name triple buffering
+    ptyn1  [8]byte
+    ptyn2  [8]byte
+    ptynab bool
+
     // radiotext triple buffering
     rt1    [64]byte
     rt2    [64]byte
     rtnew  [64]bool
+    rtseen [64]bool  // received since the message was cleared
+    rtab   bool      // text A/B flag
+    rtb    bool      // sent in 2B groups
+}
+
+// ODA is an open data application a station has announced in a 3A group
+type ODA struct {
+    AID      uint16  // application identification, ex. 0x4BD7 for RT+
+    Group    int     // group type code carrying the application (see GroupType)
+    Message  uint16  // application specific message bits from block C of 3A
+    Groups   int     // number of groups received for the application
+    Decoder  ODADecoder  // nil if no decoder is registered for the AID
+}
+
+// ODADecoder decodes the groups of one open data application for one station
+type ODADecoder interface {
+    // Group handles each group carrying the application, as well as each 3A
+    // group announcing it (group type code 6, rdsb>>11 == 6)
+    Group(r *RDS, rdsb, rdsc, rdsd uint16)
+
+    // State returns a snapshot of what's been decoded so far
+    State() interface{}
+}
+
+// RegisterODA registers a decoder for the open data application aid.  ctor
+// is called for each station that announces the application, so decoders
+// keep state per station.
+func (r *RDS) RegisterODA(aid uint16, ctor func() ODADecoder) {
+    if r.decoders == nil {
+        r.decoders = map[uint16]func() ODADecoder{}
+    }
+    r.decoders[aid] = ctor
+}
+
+// Reset forgets everything received, as when changing channels, but keeps the
+// registered ODA decoders
+func (r *RDS) Reset() {
+    *r = RDS{decoders: r.decoders}
+}
+
+// Decoder returns the current station's decoder for the application aid, or
+// nil if the station hasn't announced it or no decoder is registered
+func (r *RDS) Decoder(aid uint16) ODADecoder {
+    if oda, ok := r.odas[r.ProgramInformation][aid]; ok {
+        return oda.Decoder
+    }
+    return nil
+}
+
+// GroupType returns the group carrying the application, ex. "11A", or "" if
+// it only uses the 3A message bits
+func (o ODA) GroupType() string {
+    if o.Group == 0 || o.Group == 0x1f {
+        // 00000: not carried in an associated group, 11111: temporary data fault
+        return ""
+    }
+    if o.Group & 1 == 1 {
+        return fmt.Sprintf("%dB", o.Group>>1)
+    }
+    return fmt.Sprintf("%dA", o.Group>>1)
 }
 
 func (r *RDS) Update(rdsa, rdsb, rdsc, rdsd uint16) error {
@@ -98,6 +201,14 @@ func (r *RDS) Update(rdsa, rdsb, rdsc, rdsd uint16) error {
 
     r.update_pi(rdsa)
 
+    // the group type code: 4 bits of group type and 1 bit of version
+    if oda := r.oda_for(int(rdsb>>11)); oda != nil {
+        r.TrafficProgram = rdsb & 0x20 == 0x20
+        r.ProgramType = int((rdsb>>5) & 0x1f)
+        r.update_oda(oda, rdsb, rdsc, rdsd)
+        return nil
+    }
+
     group_type = int(rdsb>>12)
     if rdsb & 0x0800 != 0x0800 {
         version = 'A'
@@ -122,8 +233,12 @@ func (r *RDS) Update(rdsa, rdsb, rdsc, rdsd uint16) error {
                 // 3A : "Applications Identification for ODA only"
                 r.update_aid(rdsa, rdsb, rdsc, rdsd)
             } else {
-                // 3B : "Open Data Applications"
-                // TODO
+                // 3B : "Open Data Applications", handled above once announced in 3A
+            }
+        case 4:
+            if version == 'A' {
+                // 4A : "Clock Time and Date only"
+                r.update_ct(rdsa, rdsb, rdsc, rdsd)
             }
         case 8:
             // Need the specs. From U.S. RBDS Standard - April 1998, pg. 32:
@@ -131,6 +246,28 @@ func (r *RDS) Update(rdsa, rdsb, rdsc, rdsd uint16) error {
             //     use of type 1A and/or type 3A groups together with 4A groups and is separately
             //     specified by theCEN standard ENV 12313-1.
             // Also, see pg 19.
+        case 10:
+            if version == 'A' {
+                // 10A : "Program Type Name"
+                r.update_ptyn(rdsa, rdsb, rdsc, rdsd)
+            }
+        case 14:
+            if version == 'A' {
+                // 14A : "Enhanced Other Networks Information Only"
+                r.update_eon(rdsa, rdsb, rdsc, rdsd)
+            } else {
+                // 14B : traffic announcement on another network
+                r.update_eon_ta(rdsa, rdsb, rdsc, rdsd)
+            }
+        case 15:
+            if version == 'A' {
+                // 15A : Long PS in RDS2, "Defined in RBDS only" before that
+                r.update_lps(rdsa, rdsb, rdsc, rdsd)
+            } else {
+                // 15B : "Fast Switching Information only", repeats the flags
+                // of 0B in blocks B and D, so block C may be lost
+                r.update_flags(rdsb)
+            }
         default:
 //            fmt.Printf("%.4x %.4x %.4x %.4x   %.2d%c\n", rdsa, rdsb, rdsc, rdsd, group_type, version)
     }
@@ -193,28 +330,96 @@ func (r *RDS) update_pi(rdsa uint16) {
 
 /*
 Register application identification for a ODA group
+
+* rdsb: application group type code : ...._...._...x_xxxx
+* rdsc: message bits
+* rdsd: application identification (AID)
 */
 func (r *RDS) update_aid(rdsa, rdsb, rdsc, rdsd uint16) {
-    if r.aid == nil {
-        r.aid = map[uint16][32]uint16{}
+    if r.odas == nil {
+        r.odas = map[uint16]map[uint16]*ODA{}
+    }
+    if r.odas[rdsa] == nil {
+        r.odas[rdsa] = map[uint16]*ODA{}
     }
-    if _, ok := r.aid[rdsa]; !ok {
-        r.aid[rdsa] = [32]uint16{}
+    if rdsd == 0 {
+        // AID 0000 is no application, the group is used as normal
+        return
     }
 
-    oda_group := int(rdsb & 0x1f)
-// BROKEN
-    aidmap := r.aid[rdsa]
-    aidmap[oda_group] = rdsd
+    oda, ok := r.odas[rdsa][rdsd]
+    if !ok {
+        oda = &ODA{AID: rdsd}
+        r.odas[rdsa][rdsd] = oda
+    }
+    oda.Message = rdsc
+    if group := int(rdsb & 0x1f); group != 0x1f {
+        // keep the last good group type through a temporary data fault
+        oda.Group = group
+    }
+    r.decode(oda, rdsb, rdsc, rdsd)
+}
+
+// ODAs returns the open data applications announced by the current station,
+// ordered by AID
+func (r *RDS) ODAs() []ODA {
+    var out []ODA
+
+    for _, oda := range r.odas[r.ProgramInformation] {
+        out = append(out, *oda)
+    }
+    sort.Slice(out, func(i, j int) bool {
+        return out[i].AID < out[j].AID
+    })
+    return out
+}
+
+// oda_for returns the current station's application carried in the group
+// with type code group, or nil if the group isn't used for an ODA
+func (r *RDS) oda_for(group int) *ODA {
+    if !oda_group(group) {
+        return nil
+    }
+    for _, oda := range r.odas[r.ProgramInformation] {
+        if oda.Group == group {
+            return oda
+        }
+    }
+    return nil
+}
+
+// oda_group reports whether the group type code group may carry an ODA,
+// which is every group except 0A..2B, 3A, 4A, 10A, 14A..15B
+func oda_group(group int) bool {
+    switch group {
+        case 0, 1, 2, 3, 4, 5, 6, 8, 20, 28, 29, 30, 31:
+            return false
+    }
+    return true
+}
+
+// update_oda handles a group carrying an open data application
+func (r *RDS) update_oda(oda *ODA, rdsb, rdsc, rdsd uint16) {
+    oda.Groups++
+    r.decode(oda, rdsb, rdsc, rdsd)
+}
+
+// decode passes a group to the application's decoder, starting one if a
+// decoder has been registered since the application was announced
+func (r *RDS) decode(oda *ODA, rdsb, rdsc, rdsd uint16) {
+    if oda.Decoder == nil && r.decoders[oda.AID] != nil {
+        oda.Decoder = r.decoders[oda.AID]()
+    }
+    if oda.Decoder != nil {
+        oda.Decoder.Group(r, rdsb, rdsc, rdsd)
+    }
 }
 
 func (r *RDS) update_ps(rdsa, rdsb, rdsc, rdsd uint16) {
     var i, idx int
     var upd bool
 
-    //// Music and TA flags
-    r.Music = (rdsb & 0x0008) == 0x0008
-    r.TrafficAnnouncement = (rdsb & 0x0010) == 0x0010
+    r.update_flags(rdsb)
 
     //// Program Service
     for i, _ = range r.psnew {
@@ -242,14 +447,6 @@ func (r *RDS) update_ps(rdsa, rdsb, rdsc, rdsd uint16) {
         }
     }
 
-    //// Decoder Information
-    switch rdsb & 0x3 {
-        case 0: r.Stereo = (rdsb & 0x4) == 0x4
-        case 1: r.ArtificialHead = (rdsb & 0x4) == 0x4 
-        case 2: r.Compressed = (rdsb & 0x4) == 0x4
-        case 3: r.DynamicPTY = (rdsb & 0x4) == 0x4
-    }
-
     //// Alternative Frequencies
     if rdsb & 0x0800 != 0x0800 {
         // 0A 
@@ -272,38 +469,265 @@ func (r *RDS) update_ps(rdsa, rdsb, rdsc, rdsd uint16) {
     }
     // else 0B: rdsc == rdsa
 }
-func (r *RDS) update_pin(rdsa, rdsb, rdsc, rdsd uint16) {
-// TODO: stopping here...
 /*
-   var rpc, slc, d, h, m int
-   rpc = int(rdsb & 0x1f)
-   slc = int(rdsc)
-   d = int((rdsd>>11) & 0x1f)
-   h = int((rdsd>>6) & 0x1f)
-   m = int(rdsd & 0x3f)
+Long PS, RDS2
+
+* rdsb: segment address    : ...._...._...._.xxx
+* rdsc: 2 bytes
+* rdsd: 2 bytes
+
+32 bytes of UTF-8, ending with a CR if it's shorter
 */
-//   fmt.Printf("PIN: %d %.4x %d %d %d\n", rpc, slc, d, h, m)
+func (r *RDS) update_lps(rdsa, rdsb, rdsc, rdsd uint16) {
+    var i, idx int
+    var upd bool
+
+    idx = int(rdsb & 0x7) * 4
+    r.lps1[idx] = byte(rdsc>>8)
+    r.lps1[idx+1] = byte(rdsc)
+    r.lps1[idx+2] = byte(rdsd>>8)
+    r.lps1[idx+3] = byte(rdsd)
+
+    if idx == 0 {
+        // triple buffer, only update if we've seen the same thing twice
+        upd = true
+        for i=0; i<32; i++ {
+            if r.lps1[i] != r.lps2[i] {
+                upd = false
+            }
+        }
+        if upd {
+            for i=0; i<len(r.lps2) && r.lps2[i] != 0x0d && r.lps2[i] != 0; i++ {
+                // i stops at the first CR, NUL or the end
+            }
+            if utf8.Valid(r.lps2[0:i]) {
+                r.LongProgramService = string(r.lps2[0:i])
+            }
+        }
+        for i=0; i<32; i++ {
+            r.lps2[i] = r.lps1[i]
+        }
+    }
 }
 
+/*
+Program Type Name
+
+* rdsb: text A/B flag      : ...._...._...x_....
+        segment address    : ...._...._...._...x
+* rdsc: 2 chars
+* rdsd: 2 chars
+*/
+func (r *RDS) update_ptyn(rdsa, rdsb, rdsc, rdsd uint16) {
+    var i, idx int
+    var upd bool
+
+    // a change of the A/B flag means a new name
+    if ab := rdsb & 0x10 == 0x10; ab != r.ptynab {
+        r.ptynab = ab
+        r.ptyn1 = [8]byte{}
+        r.ptyn2 = [8]byte{}
+        r.ProgramTypeName = ""
+    }
+
+    idx = int(rdsb & 0x1) * 4
+    r.ptyn1[idx] = byte((rdsc>>8) & 0x7f)
+    r.ptyn1[idx+1] = byte(rdsc & 0x7f)
+    r.ptyn1[idx+2] = byte((rdsd>>8) & 0x7f)
+    r.ptyn1[idx+3] = byte(rdsd & 0x7f)
+
+    if idx == 0 {
+        // triple buffer, only update if we've seen the same thing twice
+        upd = true
+        for i=0; i<8; i++ {
+            if r.ptyn1[i] != r.ptyn2[i] {
+                upd = false
+            }
+        }
+        if upd {
+            r.ProgramTypeName = string(r.ptyn2[0:8])
+        }
+        for i=0; i<8; i++ {
+            r.ptyn2[i] = r.ptyn1[i]
+        }
+    }
+}
+
+/*
+Clock time and date, at the start of each minute
+
+* rdsb: Modified Julian Day (msb)   : ...._...._...._..xx
+* rdsc: Modified Julian Day (lsb)   : xxxx_xxxx_xxxx_xxx.
+        UTC hour (msb)              : ...._...._...._...x
+* rdsd: UTC hour (lsb)              : xxxx_...._...._....
+        UTC minute                  : ...._xxxx_xx.._....
+        local offset sign (1 is -)  : ...._...._..x._....
+        local offset (half hours)   : ...._...._...x_xxxx
+*/
+func (r *RDS) update_ct(rdsa, rdsb, rdsc, rdsd uint16) {
+    var mjd, hour, minute, offset int
+
+    r.ClockTimeReceived = time.Now()
+
+    mjd = int(rdsb & 0x3)<<15 | int(rdsc>>1)
+    hour = int(rdsc & 0x1)<<4 | int(rdsd>>12)
+    minute = int((rdsd>>6) & 0x3f)
+    offset = int(rdsd & 0x1f)
+    if rdsd & 0x20 == 0x20 {
+        offset = -offset
+    }
+
+    // 51544 is 2000-01-01, no station will send anything earlier; the largest
+    // offset in use is +14 hours
+    if mjd < 51544 || hour > 23 || minute > 59 || offset < -28 || offset > 28 {
+        r.ClockTimeValid = false
+        return
+    }
+    r.LocalOffset = time.Duration(offset) * 30 * time.Minute
+
+    utc := mjd_time(mjd).Add(time.Duration(hour) * time.Hour + time.Duration(minute) * time.Minute)
+    r.ClockTime = utc.In(time.FixedZone("", int(r.LocalOffset / time.Second)))
+    r.ClockTimeValid = true
+}
+
+// mjd_time returns the start of Modified Julian Day mjd, in UTC
+func mjd_time(mjd int) time.Time {
+    // 40587 is the Unix epoch
+    return time.Unix(int64(mjd - 40587) * 86400, 0).UTC()
+}
+
+/*
+Flags common to 0A, 0B and 15B
+
+* rdsb: TA                  : ...._...._...x_....
+        M/S                 : ...._...._...._x...
+        DI segment          : ...._...._...._.x..
+        segment address     : ...._...._...._..xx
+*/
+func (r *RDS) update_flags(rdsb uint16) {
+    //// Music and TA flags
+    r.Music = (rdsb & 0x0008) == 0x0008
+    r.TrafficAnnouncement = (rdsb & 0x0010) == 0x0010
+
+    //// Decoder Information
+    switch rdsb & 0x3 {
+        case 0: r.Stereo = (rdsb & 0x4) == 0x4
+        case 1: r.ArtificialHead = (rdsb & 0x4) == 0x4 
+        case 2: r.Compressed = (rdsb & 0x4) == 0x4
+        case 3: r.DynamicPTY = (rdsb & 0x4) == 0x4
+    }
+}
+
+/*
+Program Item Number and slow labeling codes
+
+* rdsb: radio paging codes     : ...._...._...x_xxxx (1A only)
+* rdsc: 1A: linkage actuator   : x..._...._...._....
+            variant code       : .xxx_...._...._....
+            slow labeling code : ...._xxxx_xxxx_xxxx
+        1B: PI
+* rdsd: PIN day of month       : xxxx_x..._...._....
+        PIN hour               : ...._.xxx_xx.._....
+        PIN minute             : ...._...._..xx_xxxx
+*/
+func (r *RDS) update_pin(rdsa, rdsb, rdsc, rdsd uint16) {
+    r.ProgramItem = PIN{
+        Day: int((rdsd>>11) & 0x1f),
+        Hour: int((rdsd>>6) & 0x1f),
+        Minute: int(rdsd & 0x3f),
+    }
+    if rdsb & 0x0800 == 0x0800 {
+        // 1B: rdsc == rdsa
+        return
+    }
+
+    r.LinkageActuator = rdsc & 0x8000 == 0x8000
+    slc := rdsc & 0xfff
+    switch (rdsc>>12) & 0x7 {
+        case 0:
+            r.Paging = uint8(slc>>8)
+            r.ExtendedCountryCode = uint8(slc & 0xff)
+        case 1:
+            r.TMCIdentification = slc
+        case 2:
+            r.PagingIdentification = slc
+        case 3:
+            r.LanguageCode = uint8(slc & 0xff)
+        case 6:
+            // for use by broadcasters
+        case 7:
+            r.EWSIdentification = slc
+        // 4, 5: not assigned
+    }
+}
+
+// PIN is the scheduled start of a program item, from the start of the month
+type PIN struct {
+    Day     int  // 1..31, 0 if no PIN is sent
+    Hour    int
+    Minute  int
+}
+
+func (p PIN) Valid() bool {
+    return p.Day != 0
+}
+
+func (p PIN) String() string {
+    if !p.Valid() {
+        return "--"
+    }
+    return fmt.Sprintf("%d %.2d:%.2d", p.Day, p.Hour, p.Minute)
+}
+
+/*
+Radiotext
+
+* rdsb: text A/B flag      : ...._...._...x_....
+        segment address    : ...._...._...._xxxx
+* 2A rdsc, rdsd: 4 chars, 64 chars in all
+* 2B rdsc: PI
+     rdsd: 2 chars, 32 chars in all
+
+The message ends with a CR if it's shorter.  A change of the A/B flag means a
+new message, so the display is cleared.
+*/
 func (r *RDS) update_rt(rdsa, rdsb, rdsc, rdsd uint16) {
-    var idx, cridx, i  int
-    var msgbytes [4]byte
+    var idx, cridx, i, n  int
+    var msgbytes []byte
     var upd bool
 
-    idx = int(rdsb & 0xf) * 4
-    msgbytes[0] = byte((rdsc>>8) & 0x7f)
-    msgbytes[1] = byte(rdsc & 0x7f)
-    msgbytes[2] = byte((rdsd>>8) & 0x7f)
-    msgbytes[3] = byte(rdsd & 0x7f)
+    ab := rdsb & 0x10 == 0x10
+    b := rdsb & 0x0800 == 0x0800
+    if ab != r.rtab || b != r.rtb {
+        r.rtab = ab
+        r.rtb = b
+        r.rt1 = [64]byte{}
+        r.rt2 = [64]byte{}
+        r.rtseen = [64]bool{}
+        r.Radiotext = ""
+    }
+
+    if b {
+        // 2B
+        n = 32
+        idx = int(rdsb & 0xf) * 2
+        msgbytes = []byte{byte((rdsd>>8) & 0x7f), byte(rdsd & 0x7f)}
+    } else {
+        // 2A
+        n = 64
+        idx = int(rdsb & 0xf) * 4
+        msgbytes = []byte{byte((rdsc>>8) & 0x7f), byte(rdsc & 0x7f), byte((rdsd>>8) & 0x7f), byte(rdsd & 0x7f)}
+    }
 
     for i, _ = range r.rtnew {
         r.rtnew[i] = false
     }
 
     cridx = -1
-    for i=0; i<4; i++ {
+    for i=0; i<len(msgbytes); i++ {
         r.rt1[idx+i] = msgbytes[i]
         r.rtnew[idx+i] = true
+        r.rtseen[idx+i] = true
         // 0x0d == CR (carriage return)
         if msgbytes[i] == 0x0d {
             cridx = idx+i
@@ -316,6 +740,7 @@ func (r *RDS) update_rt(rdsa, rdsb, rdsc, rdsd uint16) {
             r.rt1[i] = ' '
         }
     }
+    r.update_rt_partial(n)
 
     if idx == 0 {
         // triple buffer, only update if we've seen the same thing twice
@@ -326,7 +751,7 @@ func (r *RDS) update_rt(rdsa, rdsb, rdsc, rdsd uint16) {
             }
         }
         if upd {
-            for i=0; i<len(r.rt2) && r.rt2[i] != 0x0d; i++ {
+            for i=0; i<n && r.rt2[i] != 0x0d; i++ {
                 // i stops at the first CR or the end
             }
             r.Radiotext = string(r.rt2[0:i])
@@ -337,6 +762,22 @@ func (r *RDS) update_rt(rdsa, rdsb, rdsc, rdsd uint16) {
     }
 }
 
+// update_rt_partial shows the n char message as received so far, with
+// spaces for the segments that haven't arrived
+func (r *RDS) update_rt_partial(n int) {
+    var i int
+
+    partial := make([]byte, 0, n)
+    for i=0; i<n && r.rt1[i] != 0x0d; i++ {
+        if r.rtseen[i] {
+            partial = append(partial, r.rt1[i])
+        } else {
+            partial = append(partial, ' ')
+        }
+    }
+    r.RadiotextPartial = strings.TrimRight(string(partial), " ")
+}
+
 var PT_NA [32]string = [32]string {
     "No program type",
     "News",
@@ -0,0 +1,234 @@
+package main
+
+import (
+    "strings"
+    "testing"
+    "time"
+)
+
+// annex_g_mjd is the spec's Annex G conversion of a date to a Modified Julian
+// Day, written out separately from mjd_time to check it and the test groups
+func annex_g_mjd(year int, month time.Month, day int) int {
+    var l int
+
+    y, m := year - 1900, int(month)
+    if m == 1 || m == 2 {
+        l = 1
+    }
+    return 14956 + day + int(float64(y - l) * 365.25) + int(float64(m + 1 + l*12) * 30.6001)
+}
+
+func TestMJDTime(t *testing.T) {
+    tests := []struct {
+        mjd     int
+        want    time.Time
+    }{
+        {40587, time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)},
+        {45218, time.Date(1982, 9, 6, 0, 0, 0, 0, time.UTC)},  // the spec's Annex G example
+        {51544, time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+    }
+    for _, tt := range tests {
+        if got := mjd_time(tt.mjd); !got.Equal(tt.want) {
+            t.Errorf("mjd_time(%d) = %v, want %v", tt.mjd, got, tt.want)
+        }
+        if got := annex_g_mjd(tt.want.Date()); got != tt.mjd {
+            t.Errorf("annex_g_mjd(%v) = %d, want %d", tt.want, got, tt.mjd)
+        }
+    }
+}
+
+// The 4A groups below aren't from the spec, which has no worked example of a
+// whole CT group.  Each was put together by hand from the bit layout above
+// update_ct: block B is PTY 5 and the top MJD bits, the MJD is the utc date
+// by the Annex G formula (checked in the loop), and block D is the hour,
+// minute and signed offset in half hours.
+func TestClockTime(t *testing.T) {
+    tests := []struct {
+        name    string
+        rdsb    uint16
+        rdsc    uint16
+        rdsd    uint16
+        utc     time.Time
+        local   string  // wall clock in the station's zone
+        offset  time.Duration
+    }{
+        {"positive offset", 0x40a1, 0xdf23, 0x21c4,
+            time.Date(2026, 10, 16, 18, 7, 0, 0, time.UTC), "2026-10-16 20:07 +0200", 2 * time.Hour},
+        {"negative offset", 0x40a1, 0xd7b6, 0xe8ea,
+            time.Date(2024, 3, 10, 14, 35, 0, 0, time.UTC), "2024-03-10 09:35 -0500", -5 * time.Hour},
+        {"half hour offset", 0x40a1, 0xd748, 0x600b,
+            time.Date(2024, 1, 15, 6, 0, 0, 0, time.UTC), "2024-01-15 11:30 +0530", 5 * time.Hour + 30 * time.Minute},
+        {"local date ahead", 0x40a1, 0xd72b, 0x7782,
+            time.Date(2023, 12, 31, 23, 30, 0, 0, time.UTC), "2024-01-01 00:30 +0100", time.Hour},
+        {"no offset", 0x40a1, 0xd89e, 0xc000,
+            time.Date(2024, 7, 4, 12, 0, 0, 0, time.UTC), "2024-07-04 12:00 +0000", 0},
+    }
+    for _, tt := range tests {
+        if mjd := int(tt.rdsb & 0x3)<<15 | int(tt.rdsc>>1); mjd != annex_g_mjd(tt.utc.Date()) {
+            t.Errorf("%s: the group has MJD %d, Annex G gives %d", tt.name, mjd, annex_g_mjd(tt.utc.Date()))
+        }
+        r := RDS{}
+        r.Update(0x1234, tt.rdsb, tt.rdsc, tt.rdsd)
+        if !r.ClockTimeValid {
+            t.Errorf("%s: ClockTimeValid false", tt.name)
+            continue
+        }
+        if !r.ClockTime.Equal(tt.utc) {
+            t.Errorf("%s: ClockTime %v, want %v", tt.name, r.ClockTime.UTC(), tt.utc)
+        }
+        if local := r.ClockTime.Format("2006-01-02 15:04 -0700"); local != tt.local {
+            t.Errorf("%s: local time %s, want %s", tt.name, local, tt.local)
+        }
+        if r.LocalOffset != tt.offset {
+            t.Errorf("%s: LocalOffset %v, want %v", tt.name, r.LocalOffset, tt.offset)
+        }
+    }
+}
+
+func TestClockTimeInvalid(t *testing.T) {
+    tests := []struct {
+        name    string
+        rdsb    uint16
+        rdsc    uint16
+        rdsd    uint16
+    }{
+        // the 2024-07-04 12:00 UTC group above with one field out of range
+        {"hour 24", 0x40a1, 0xd89f, 0x8000},
+        {"minute 60", 0x40a1, 0xd89e, 0xcf00},
+        {"MJD before 2000", 0x40a1, 0x3880, 0xc000},  // MJD 40000
+        {"offset 15.5 hours", 0x40a1, 0xd89e, 0xc01f},
+    }
+    for _, tt := range tests {
+        r := RDS{}
+        // a good CT first, the bad one must clear it
+        r.Update(0x1234, 0x40a1, 0xd89e, 0xc000)
+        r.Update(0x1234, tt.rdsb, tt.rdsc, tt.rdsd)
+        if r.ClockTimeValid {
+            t.Errorf("%s: ClockTimeValid true", tt.name)
+        }
+        if r.ClockTimeReceived.IsZero() {
+            t.Errorf("%s: ClockTimeReceived not set", tt.name)
+        }
+    }
+}
+
+// rt_groups returns the 2A groups, or 2B if b, carrying text from segment
+// first on, with the A/B flag ab
+func rt_groups(text string, first int, ab, b bool) [][3]uint16 {
+    var groups [][3]uint16
+
+    n := 4
+    rdsb := uint16(0x2000)
+    if b {
+        n = 2
+        rdsb |= 0x0800
+    }
+    if ab {
+        rdsb |= 0x10
+    }
+    for i := 0; i < len(text); i += n {
+        var chars [4]uint16
+        for j := 0; j < n && i+j < len(text); j++ {
+            chars[j] = uint16(text[i+j])
+        }
+        seg := rdsb | uint16(first + i/n)
+        if b {
+            groups = append(groups, [3]uint16{seg, 0x1234, chars[0]<<8 | chars[1]})
+        } else {
+            groups = append(groups, [3]uint16{seg, chars[0]<<8 | chars[1], chars[2]<<8 | chars[3]})
+        }
+    }
+    return groups
+}
+
+func send(r *RDS, groups ...[3]uint16) {
+    for _, g := range groups {
+        r.Update(0x1234, g[0], g[1], g[2])
+    }
+}
+
+func TestRadiotext(t *testing.T) {
+    tests := []struct {
+        name   string
+        b      bool
+        text   string
+        want   string
+    }{
+        {"2A", false, "Hello world\r", "Hello world"},
+        {"2A, 64 chars", false, strings.Repeat("0123456789abcdef", 4), strings.Repeat("0123456789abcdef", 4)},
+        {"2B", true, "KQED news\r", "KQED news"},
+        {"2B, 32 chars", true, "0123456789abcdef0123456789abcdef", "0123456789abcdef0123456789abcdef"},
+    }
+    for _, tt := range tests {
+        r := RDS{}
+        msg := rt_groups(tt.text, 0, false, tt.b)
+        send(&r, msg...)
+        if r.Radiotext != "" {
+            t.Errorf("%s: Radiotext %q after one pass", tt.name, r.Radiotext)
+        }
+        if r.RadiotextPartial != tt.want {
+            t.Errorf("%s: RadiotextPartial %q, want %q", tt.name, r.RadiotextPartial, tt.want)
+        }
+        // confirmed once the whole message has been seen twice
+        send(&r, msg...)
+        send(&r, msg[0])
+        if r.Radiotext != tt.want {
+            t.Errorf("%s: Radiotext %q, want %q", tt.name, r.Radiotext, tt.want)
+        }
+    }
+}
+
+func TestRadiotextPartial(t *testing.T) {
+    tests := []struct {
+        name    string
+        groups  [][3]uint16
+        want    string
+    }{
+        {"2A gap", append(rt_groups("Hell", 0, false, false), rt_groups("rld\r", 2, false, false)...), "Hell    rld"},
+        {"2A last segment", rt_groups("wxyz", 15, false, false), strings.Repeat(" ", 60) + "wxyz"},
+        {"2B gap", append(rt_groups("KQ", 0, false, true), rt_groups(" n", 2, false, true)...), "KQ   n"},
+        {"2B last segment", rt_groups("xy", 15, false, true), strings.Repeat(" ", 30) + "xy"},
+    }
+    for _, tt := range tests {
+        r := RDS{}
+        send(&r, tt.groups...)
+        if r.RadiotextPartial != tt.want {
+            t.Errorf("%s: RadiotextPartial %q, want %q", tt.name, r.RadiotextPartial, tt.want)
+        }
+    }
+}
+
+func TestRadiotextAB(t *testing.T) {
+    r := RDS{}
+    old := rt_groups("Old message\r", 0, false, false)
+    send(&r, old...)
+    send(&r, old...)
+    send(&r, old[0])
+    if r.Radiotext != "Old message" {
+        t.Fatalf("Radiotext %q, want %q", r.Radiotext, "Old message")
+    }
+
+    // the flag flips part way into the new message: the old one is cleared
+    // and only the new segments are shown
+    send(&r, rt_groups("New ", 1, true, false)...)
+    if r.Radiotext != "" {
+        t.Errorf("Radiotext %q after the A/B flag changed", r.Radiotext)
+    }
+    if want := "    New"; r.RadiotextPartial != want {
+        t.Errorf("RadiotextPartial %q, want %q", r.RadiotextPartial, want)
+    }
+
+    msg := rt_groups("The New one\r", 0, true, false)
+    send(&r, msg...)
+    send(&r, msg...)
+    send(&r, msg[0])
+    if r.Radiotext != "The New one" {
+        t.Errorf("Radiotext %q, want %q", r.Radiotext, "The New one")
+    }
+
+    // so does a switch between 2A and 2B
+    send(&r, rt_groups("2B", 0, true, true)...)
+    if r.Radiotext != "" || r.RadiotextPartial != "2B" {
+        t.Errorf("after 2B: Radiotext %q, RadiotextPartial %q", r.Radiotext, r.RadiotextPartial)
+    }
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+    "strings"
+)
+
+/*
+RadioText Plus (RT+) tags substrings of the radiotext with content types.
+See: "Specification of the Radiotext Plus (RT+) feature", RDS Forum R06/040_1
+
+* 3A message bits : rfu, CB flag, server control bits, template number
+* rdsb: Item Toggle            : ...._...._...x_....
+        Item Running           : ...._...._...._x...
+        Content Type 1 (msb)   : ...._...._...._.xxx
+* rdsc: Content Type 1 (lsb)   : xxx._...._...._....
+        Start Marker 1         : ...x_xxxx_x..._....
+        Length Marker 1        : ...._...._.xxx_xxx.
+        Content Type 2 (msb)   : ...._...._...._...x
+* rdsd: Content Type 2 (lsb)   : xxxx_x..._...._....
+        Start Marker 2         : ...._.xxx_xxx._....
+        Length Marker 2        : ...._...._...x_xxxx
+
+The length markers are one less than the length of the tagged text.
+*/
+
+const RTPlusAID uint16 = 0x4BD7
+
+// NowPlaying is what RT+ says is on the air
+type NowPlaying struct {
+    Title        string
+    Artist       string
+    Album        string
+    Band         string
+    Composer     string
+    Station      string
+    Program      string
+
+    // every tagged string by content type, see RTPlusTypes
+    Tags         map[int]string
+
+    ItemToggle   bool  // flips when a new item (song, etc.) starts
+    ItemRunning  bool  // the item is on the air
+}
+
+// RTPlus decodes RT+ for one station
+type RTPlus struct {
+    NowPlaying  NowPlaying
+    seen        bool  // whether ItemToggle has been received
+}
+
+func NewRTPlus() ODADecoder {
+    return &RTPlus{}
+}
+
+func (p *RTPlus) State() interface{} {
+    return p.snapshot()
+}
+
+// snapshot returns a copy of NowPlaying that later groups won't change
+func (p *RTPlus) snapshot() NowPlaying {
+    np := p.NowPlaying
+    np.Tags = make(map[int]string, len(p.NowPlaying.Tags))
+    for t, s := range p.NowPlaying.Tags {
+        np.Tags[t] = s
+    }
+    return np
+}
+
+func (p *RTPlus) Group(r *RDS, rdsb, rdsc, rdsd uint16) {
+    if rdsb>>11 == 6 {
+        // the 3A announcement, nothing needed from the message bits
+        return
+    }
+
+    toggle := rdsb & 0x10 == 0x10
+    if p.seen && toggle != p.NowPlaying.ItemToggle {
+        // a new item, forget the old one's tags
+        for t := range p.NowPlaying.Tags {
+            if t >= 1 && t <= 11 {
+                delete(p.NowPlaying.Tags, t)
+            }
+        }
+    }
+    p.seen = true
+    p.NowPlaying.ItemToggle = toggle
+    p.NowPlaying.ItemRunning = rdsb & 0x08 == 0x08
+
+    p.tag(r, int((rdsb & 0x7)<<3 | rdsc>>13), int((rdsc>>7) & 0x3f), int((rdsc>>1) & 0x3f))
+    p.tag(r, int((rdsc & 0x1)<<5 | rdsd>>11), int((rdsd>>5) & 0x3f), int(rdsd & 0x1f))
+    p.update()
+}
+
+// tag records the radiotext from start to start+length as content type t
+func (p *RTPlus) tag(r *RDS, t, start, length int) {
+    if t == 0 {
+        // DUMMY_CLASS
+        return
+    }
+    // the tags describe the message being received, which may not be
+    // confirmed in Radiotext yet
+    if start + length >= len(r.rt1) {
+        return
+    }
+    s := string(r.rt1[start:start+length+1])
+    if strings.ContainsAny(s, "\x00\r") {
+        // not received yet, or past the end of the message
+        return
+    }
+    if s = strings.TrimSpace(s); s == "" {
+        return
+    }
+    if p.NowPlaying.Tags == nil {
+        p.NowPlaying.Tags = map[int]string{}
+    }
+    p.NowPlaying.Tags[t] = s
+}
+
+// update fills in the named fields from the tags
+func (p *RTPlus) update() {
+    np := &p.NowPlaying
+    np.Title = np.Tags[1]
+    np.Album = np.Tags[2]
+    np.Artist = np.Tags[4]
+    np.Band = np.Tags[9]
+    np.Composer = np.Tags[8]
+    np.Station = np.Tags[32]
+    if np.Station == "" {
+        np.Station = np.Tags[31]
+    }
+    np.Program = np.Tags[33]
+}
+
+// NowPlaying returns the current station's RT+ information, if it sends RT+
+// and an RT+ decoder is registered
+func (r *RDS) NowPlaying() (NowPlaying, bool) {
+    if p, ok := r.Decoder(RTPlusAID).(*RTPlus); ok {
+        return p.snapshot(), true
+    }
+    return NowPlaying{}, false
+}
+
+var RTPlusTypes [64]string = [64]string{
+    "DUMMY_CLASS",
+    "ITEM.TITLE",
+    "ITEM.ALBUM",
+    "ITEM.TRACKNUMBER",
+    "ITEM.ARTIST",
+    "ITEM.COMPOSITION",
+    "ITEM.MOVEMENT",
+    "ITEM.CONDUCTOR",
+    "ITEM.COMPOSER",
+    "ITEM.BAND",
+    "ITEM.COMMENT",
+    "ITEM.GENRE",
+    "INFO.NEWS",
+    "INFO.NEWS.LOCAL",
+    "INFO.STOCKMARKET",
+    "INFO.SPORT",
+    "INFO.LOTTERY",
+    "INFO.HOROSCOPE",
+    "INFO.DAILY_DIVERSION",
+    "INFO.HEALTH",
+    "INFO.EVENT",
+    "INFO.SZENE",
+    "INFO.CINEMA",
+    "INFO.STUPIDITY.TV",
+    "INFO.DATE_TIME",
+    "INFO.WEATHER",
+    "INFO.TRAFFIC",
+    "INFO.ALARM",
+    "INFO.ADVERTISEMENT",
+    "INFO.URL",
+    "INFO.OTHER",
+    "STATIONNAME.SHORT",
+    "STATIONNAME.LONG",
+    "PROGRAMME.NOW",
+    "PROGRAMME.NEXT",
+    "PROGRAMME.PART",
+    "PROGRAMME.HOST",
+    "PROGRAMME.EDITORIAL_STAFF",
+    "PROGRAMME.FREQUENCY",
+    "PROGRAMME.HOMEPAGE",
+    "PROGRAMME.SUBCHANNEL",
+    "PHONE.HOTLINE",
+    "PHONE.STUDIO",
+    "PHONE.OTHER",
+    "SMS.STUDIO",
+    "SMS.OTHER",
+    "EMAIL.HOTLINE",
+    "EMAIL.STUDIO",
+    "EMAIL.OTHER",
+    "MMS.OTHER",
+    "CHAT",
+    "CHAT.CENTRE",
+    "VOTE.QUESTION",
+    "VOTE.CENTRE",
+    "RFU",
+    "RFU",
+    "PRIVATE",
+    "PRIVATE",
+    "PRIVATE",
+    "PLACE",
+    "APPOINTMENT",
+    "IDENTIFIER",
+    "PURCHASE",
+    "GET_DATA",
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+    "reflect"
+    "testing"
+)
+
+func TestRTPlus(t *testing.T) {
+    r := RDS{}
+    r.RegisterODA(RTPlusAID, NewRTPlus)
+
+    // 3A: RT+ is carried in 11A
+    send(&r, [3]uint16{0x3016, 0x0000, 0x4bd7})
+    //           0         1         2
+    //           0123456789012345678901234567
+    send(&r, rt_groups("KQED plays Madonna - Frozen\r", 0, false, false)...)
+
+    // item running, ITEM.TITLE at 21 for 6, ITEM.ARTIST at 11 for 7
+    send(&r, [3]uint16{0xb008, 0x2a8a, 0x2166})
+    // STATIONNAME.SHORT at 0 for 4, and DUMMY_CLASS
+    send(&r, [3]uint16{0xb00b, 0xe006, 0x0000})
+
+    np, ok := r.NowPlaying()
+    if !ok {
+        t.Fatal("no RT+ decoder")
+    }
+    want := map[int]string{1: "Frozen", 4: "Madonna", 31: "KQED"}
+    if np.Title != "Frozen" || np.Artist != "Madonna" || np.Station != "KQED" || !reflect.DeepEqual(np.Tags, want) {
+        t.Errorf("got %+v", np)
+    }
+    if !np.ItemRunning || np.ItemToggle {
+        t.Errorf("ItemRunning %v, ItemToggle %v, want true, false", np.ItemRunning, np.ItemToggle)
+    }
+
+    // the item toggle flips: a new item with nothing tagged yet
+    send(&r, [3]uint16{0xb018, 0x0000, 0x0000})
+    np, _ = r.NowPlaying()
+    if np.Title != "" || np.Artist != "" || !reflect.DeepEqual(np.Tags, map[int]string{31: "KQED"}) {
+        t.Errorf("after the item toggle: %+v", np)
+    }
+    if !np.ItemToggle {
+        t.Error("ItemToggle false")
+    }
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+    "image/color"
+
     "github.com/gdamore/tcell"
+    "github.com/mattn/go-runewidth"
 )
 
 func Clear(scr tcell.Screen, x, y, h, w int, c rune, style tcell.Style) {
@@ -13,9 +16,80 @@ func Clear(scr tcell.Screen, x, y, h, w int, c rune, style tcell.Style) {
 }
 
 func DrawLines(scr tcell.Screen, x, y int, style tcell.Style, lines []string) {
+    var i int
+
     for j, line := range lines {
-        for i, c := range line {
+        i = 0
+        for _, c := range line {
             scr.SetContent(x+i, y+j, c, nil, style)
+            i += runewidth.RuneWidth(c)
         }
     }
 }
+
+func DrawRunes(scr tcell.Screen, x, y int, style tcell.Style, rows [][]rune) {
+    var i int
+
+    for j, row := range rows {
+        i = 0
+        for _, c := range row {
+            scr.SetContent(x+i, y+j, c, nil, style)
+            i += runewidth.RuneWidth(c)
+        }
+    }
+}
+
+// Style returns base with the cell's colors applied
+func (c Cell) Style(base tcell.Style) tcell.Style {
+    if c.Fg != nil {
+        r, g, b := rgb(c.Fg)
+        base = base.Foreground(tcell.NewRGBColor(int32(r), int32(g), int32(b)))
+    }
+    if c.Bg != nil {
+        r, g, b := rgb(c.Bg)
+        base = base.Background(tcell.NewRGBColor(int32(r), int32(g), int32(b)))
+    }
+    return base
+}
+
+func DrawCells(scr tcell.Screen, x, y int, style tcell.Style, cells [][]Cell) {
+    var i int
+
+    for j, row := range cells {
+        i = 0
+        for _, c := range row {
+            scr.SetContent(x+i, y+j, c.Rune, nil, c.Style(style))
+            i += runewidth.RuneWidth(c.Rune)
+        }
+    }
+}
+
+// ScreenCells copies the contents of scr, for rendering it as an image
+func ScreenCells(scr tcell.Screen) [][]Cell {
+    var cells [][]Cell
+
+    w, h := scr.Size()
+    for y:=0; y<h; y++ {
+        row := []Cell{}
+        for x:=0; x<w; {
+            c, _, style, width := scr.GetContent(x, y)
+            fg, bg, _ := style.Decompose()
+            row = append(row, Cell{Rune: c, Fg: tcell_color(fg), Bg: tcell_color(bg)})
+            if width < 1 {
+                width = 1
+            }
+            x += width
+        }
+        cells = append(cells, row)
+    }
+    return cells
+}
+
+// tcell_color converts a tcell color, returning nil for the default
+func tcell_color(c tcell.Color) color.Color {
+    r, g, b := c.RGB()
+    if c == tcell.ColorDefault || r < 0 {
+        return nil
+    }
+    return color.RGBA{uint8(r), uint8(g), uint8(b), 0xff}
+}